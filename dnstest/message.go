@@ -70,6 +70,14 @@ func A(hdr dns.RR_Header, ip net.IP) *dns.A {
 	}
 }
 
+// AAAA returns an AAAA record set with the given arguments.
+func AAAA(hdr dns.RR_Header, ip net.IP) *dns.AAAA {
+	return &dns.AAAA{
+		Hdr:  hdr,
+		AAAA: ip.To16(),
+	}
+}
+
 // SRV returns a SRV record set with the given arguments.
 func SRV(hdr dns.RR_Header, target string, port, priority, weight uint16) *dns.SRV {
 	return &dns.SRV{
@@ -81,6 +89,39 @@ func SRV(hdr dns.RR_Header, target string, port, priority, weight uint16) *dns.S
 	}
 }
 
+// PTR returns a PTR record set with the given arguments.
+func PTR(hdr dns.RR_Header, ptr string) *dns.PTR {
+	return &dns.PTR{
+		Hdr: hdr,
+		Ptr: ptr,
+	}
+}
+
+// CNAME returns a CNAME record set with the given arguments.
+func CNAME(hdr dns.RR_Header, target string) *dns.CNAME {
+	return &dns.CNAME{
+		Hdr:    hdr,
+		Target: target,
+	}
+}
+
+// TXT returns a TXT record set with the given arguments.
+func TXT(hdr dns.RR_Header, txt ...string) *dns.TXT {
+	return &dns.TXT{
+		Hdr: hdr,
+		Txt: txt,
+	}
+}
+
+// MX returns a MX record set with the given arguments.
+func MX(hdr dns.RR_Header, mx string, preference uint16) *dns.MX {
+	return &dns.MX{
+		Hdr:        hdr,
+		Mx:         mx,
+		Preference: preference,
+	}
+}
+
 // NS returns a NS record set with the given arguments.
 func NS(hdr dns.RR_Header, ns string) *dns.NS {
 	return &dns.NS{
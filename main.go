@@ -25,6 +25,7 @@ func main() {
 
 	// parse flags
 	cjson := flag.String("config", "config.json", "path to config file (json)")
+	dumpZone := flag.String("dump-zone", "", "write the records of the given zone, e.g. mesos, to stdout as an RFC 1035 master file and exit")
 	flag.BoolVar(&versionFlag, "version", false, "output the version")
 	flag.Parse()
 
@@ -40,6 +41,16 @@ func main() {
 	// initialize resolver
 	config := records.SetConfig(*cjson)
 	res := resolver.New(version, config)
+
+	// -dump-zone
+	if *dumpZone != "" {
+		res.Reload()
+		if err := res.WriteZone(os.Stdout, *dumpZone); err != nil {
+			logging.Error.Fatal(err)
+		}
+		os.Exit(0)
+	}
+
 	errch := make(chan error)
 
 	// launch DNS server
@@ -77,6 +88,11 @@ func main() {
 	})
 
 	res.Reload()
+	if config.StateSource == "subscribe" {
+		// the event stream of the leader replaces periodic reloads
+		reload.Stop()
+		go res.Subscribe(nil)
+	}
 	defer reload.Stop()
 	defer util.HandleCrash()
 	for {
@@ -87,7 +103,13 @@ func main() {
 			timeout.Stop()
 			logging.VeryVerbose.Printf("new masters detected: %v", masters)
 			res.SetMasters(masters)
-			res.Reload()
+			if config.StateSource == "subscribe" {
+				// reloading alongside the subscription could replace the
+				// records of newer events
+				res.Resubscribe()
+			} else {
+				res.Reload()
+			}
 		case err := <-errch:
 			logging.VeryVerbose.Println(err)
 		case isConnected := <-connected:
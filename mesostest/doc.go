@@ -0,0 +1,2 @@
+// Package mesostest provides Mesos testing utilities, such as a fake master.
+package mesostest
@@ -0,0 +1,166 @@
+package mesostest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Master is a fake Mesos master. It serves the GET_MASTER, GET_STATE and
+// SUBSCRIBE calls of the v1 operator API from a state given as the JSON of a
+// get_state object, and streams the events sent to it to its subscribers. It's
+// used in tests only.
+type Master struct {
+	// Addr is the address the master listens on, e.g. "127.0.0.1:5050"
+	Addr string
+
+	srv        *httptest.Server
+	mu         sync.Mutex
+	state      string
+	heartbeat  time.Duration
+	streams    map[chan string]struct{}
+	subscribed chan struct{}
+}
+
+// NewMaster starts a fake master with the given state, the JSON of a
+// get_state object, e.g. `{"get_frameworks": {"frameworks": [...]}}`.
+func NewMaster(state string) *Master {
+	m := &Master{
+		state:      state,
+		heartbeat:  15 * time.Second,
+		streams:    map[chan string]struct{}{},
+		subscribed: make(chan struct{}, 16),
+	}
+	m.srv = httptest.NewServer(http.HandlerFunc(m.serve))
+	m.Addr = strings.TrimPrefix(m.srv.URL, "http://")
+	return m
+}
+
+// Close ends all subscriptions and shuts the master down.
+func (m *Master) Close() {
+	m.Disconnect()
+	m.srv.Close()
+}
+
+// SetState sets the state served by GET_STATE and by the SUBSCRIBED event of
+// subsequent subscriptions.
+func (m *Master) SetState(state string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state = state
+}
+
+// SetHeartbeatInterval sets the heartbeat interval announced to subsequent
+// subscriptions. The master doesn't send HEARTBEAT events by itself.
+func (m *Master) SetHeartbeatInterval(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.heartbeat = d
+}
+
+// Subscribed returns a channel receiving a value whenever a subscription
+// received its SUBSCRIBED event.
+func (m *Master) Subscribed() <-chan struct{} {
+	return m.subscribed
+}
+
+// Send streams the given event, in JSON, to all subscribers.
+func (m *Master) Send(event string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for ch := range m.streams {
+		ch <- event
+	}
+}
+
+// Disconnect ends all subscriptions.
+func (m *Master) Disconnect() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for ch := range m.streams {
+		delete(m.streams, ch)
+		close(ch)
+	}
+}
+
+// serve handles the calls of the v1 operator API.
+func (m *Master) serve(w http.ResponseWriter, r *http.Request) {
+	var call struct {
+		Type string `json:"type"`
+	}
+	if r.Method != "POST" || r.URL.Path != "/api/v1" {
+		http.NotFound(w, r)
+		return
+	} else if err := json.NewDecoder(r.Body).Decode(&call); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	state, heartbeat := m.state, m.heartbeat
+	m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch call.Type {
+	case "GET_MASTER":
+		_, _ = fmt.Fprintf(w, `{"type": "GET_MASTER", "get_master": {"master_info": {"pid": "master@%s"}}}`, m.Addr)
+	case "GET_STATE":
+		_, _ = fmt.Fprintf(w, `{"type": "GET_STATE", "get_state": %s}`, state)
+	case "SUBSCRIBE":
+		m.stream(w, r, fmt.Sprintf(`{"type": "SUBSCRIBED", "subscribed": {"get_state": %s, "heartbeat_interval_seconds": %g}}`,
+			state, heartbeat.Seconds()))
+	default:
+		http.Error(w, "unsupported call "+call.Type, http.StatusBadRequest)
+	}
+}
+
+// stream streams the given SUBSCRIBED event followed by the events sent to
+// the master, until the subscription ends.
+func (m *Master) stream(w http.ResponseWriter, r *http.Request, subscribed string) {
+	ch := make(chan string, 64)
+	m.mu.Lock()
+	m.streams[ch] = struct{}{}
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if _, ok := m.streams[ch]; ok {
+			delete(m.streams, ch)
+			close(ch)
+		}
+	}()
+
+	w.Header().Set("Content-Type", "application/recordio")
+	w.WriteHeader(http.StatusOK)
+	writeRecord(w, subscribed)
+	select {
+	case m.subscribed <- struct{}{}:
+	default:
+	}
+
+	for {
+		select {
+		case event, ok := <-ch:
+			if !ok {
+				return
+			}
+			writeRecord(w, event)
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeRecord writes the given data in the RecordIO format of the event
+// stream and flushes it.
+func writeRecord(w io.Writer, data string) {
+	_, _ = fmt.Fprintf(w, "%d\n%s", len(data), data)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,203 @@
+package records
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/mesosphere/mesos-dns/logging"
+)
+
+// masterClient sends requests to the Mesos masters with the scheme, TLS
+// settings and credentials of a Config.
+type masterClient struct {
+	scheme string
+	client *http.Client
+
+	// HTTP basic authentication credentials
+	principal, secret string
+	// bearer token, which takes precedence over the credentials
+	token string
+
+	// names and addresses of the known masters, which redirects may send the
+	// credentials to
+	mu    sync.Mutex
+	hosts map[string]bool
+}
+
+// defaultMasterClient sends plain HTTP requests without credentials.
+var defaultMasterClient = &masterClient{scheme: "http", client: &http.Client{}, hosts: map[string]bool{}}
+
+// credentials holds the credentials of a Mesos principal, in the JSON format
+// of the credential files of Mesos.
+type credentials struct {
+	Principal string `json:"principal"`
+	Secret    string `json:"secret"`
+}
+
+// newMasterClient returns a masterClient with the settings of the given
+// config, reading the files it refers to.
+func newMasterClient(c Config) (*masterClient, error) {
+	mc := &masterClient{scheme: c.MesosScheme, token: c.MesosAuthToken, hosts: map[string]bool{}}
+	if mc.scheme == "" {
+		mc.scheme = "http"
+	}
+	mc.trust(c.Masters...)
+
+	var tlsConfig *tls.Config
+	if c.MesosCACertFile != "" || c.MesosCertFile != "" || c.MesosKeyFile != "" {
+		tlsConfig = &tls.Config{}
+	}
+	if c.MesosCACertFile != "" {
+		pem, err := ioutil.ReadFile(c.MesosCACertFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", c.MesosCACertFile)
+		}
+	}
+	if c.MesosCertFile != "" || c.MesosKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.MesosCertFile, c.MesosKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if c.MesosCredentialsFile != "" {
+		data, err := ioutil.ReadFile(c.MesosCredentialsFile)
+		if err != nil {
+			return nil, err
+		}
+		var cred credentials
+		if err = json.Unmarshal(data, &cred); err != nil {
+			return nil, fmt.Errorf("malformed credentials in %s: %v", c.MesosCredentialsFile, err)
+		} else if cred.Principal == "" {
+			return nil, fmt.Errorf("missing principal in %s", c.MesosCredentialsFile)
+		}
+		mc.principal, mc.secret = cred.Principal, cred.Secret
+	}
+
+	mc.client = &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		},
+		CheckRedirect: mc.checkRedirect,
+	}
+	return mc, nil
+}
+
+// checkRedirect prepares the given redirected request. Non-leading masters
+// redirect to the leader, which needs the credentials too, but they're only
+// sent to known masters without downgrading the scheme.
+func (mc *masterClient) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if len(via) > 0 && req.URL.Scheme == via[0].URL.Scheme && mc.known(req.URL.Hostname()) {
+		mc.authorize(req)
+	} else {
+		req.Header.Del("Authorization")
+	}
+	return nil
+}
+
+// trust adds the masters at the given addresses, as host:port or host, to
+// the known masters. Their host names are resolved, so that redirects to
+// other names of the same masters are known too.
+func (mc *masterClient) trust(masters ...string) {
+	for _, master := range masters {
+		host, _, err := net.SplitHostPort(master)
+		if err != nil {
+			host = master
+		}
+		if host == "" {
+			continue
+		}
+
+		mc.mu.Lock()
+		known := mc.hosts[host]
+		mc.hosts[host] = true
+		mc.mu.Unlock()
+		if known {
+			continue
+		}
+
+		addrs, err := lookupAddrs(host)
+		if err != nil {
+			logging.VeryVerbose.Printf("cannot resolve master %s: %v", host, err)
+		}
+		mc.mu.Lock()
+		for _, addr := range addrs {
+			mc.hosts[addr] = true
+		}
+		mc.mu.Unlock()
+	}
+}
+
+// known returns whether the given host is a known master, by name or by any
+// of its addresses.
+func (mc *masterClient) known(host string) bool {
+	mc.mu.Lock()
+	known := mc.hosts[host]
+	mc.mu.Unlock()
+	if known {
+		return true
+	}
+
+	addrs, _ := lookupAddrs(host)
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	for _, addr := range addrs {
+		if mc.hosts[addr] {
+			return true
+		}
+	}
+	return false
+}
+
+// trustLeader adds the master of the given leader PID, in the format
+// master@host:port, to the known masters.
+func (mc *masterClient) trustLeader(pid string) {
+	if i := strings.LastIndex(pid, "@"); i >= 0 {
+		mc.trust(pid[i+1:])
+	}
+}
+
+// url returns the URL of the given path on the master at the given address.
+func (mc *masterClient) url(addr, path string) string {
+	u := url.URL{
+		Scheme: mc.scheme,
+		Host:   addr,
+		Path:   path,
+	}
+	return u.String()
+}
+
+// authorize adds the credentials to the given request.
+func (mc *masterClient) authorize(req *http.Request) {
+	if mc.token != "" {
+		req.Header.Set("Authorization", "Bearer "+mc.token)
+	} else if mc.principal != "" {
+		req.SetBasicAuth(mc.principal, mc.secret)
+	}
+}
+
+// do sends the given request with the credentials. The host it's sent to is
+// known to be a master from then on.
+func (mc *masterClient) do(req *http.Request) (*http.Response, error) {
+	mc.trust(req.URL.Hostname())
+	mc.authorize(req)
+	return mc.client.Do(req)
+}
@@ -0,0 +1,308 @@
+package records
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// writeFile writes the given data to the file of the given name in dir and
+// returns its path.
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeKeyPair writes a self-signed client certificate and its key to dir and
+// returns their paths.
+func writeKeyPair(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "mesos-dns"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeFile(t, dir, "cert.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		writeFile(t, dir, "key.pem", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+func TestMasterClient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mesos-dns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	// the masters require client certificates and record the credentials of
+	// the requests
+	var (
+		mu         sync.Mutex
+		auths      []string
+		leaderAddr string
+	)
+	leader := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		auths = append(auths, r.Header.Get("Authorization"))
+		mu.Unlock()
+
+		var call struct{ Type string }
+		_ = json.NewDecoder(r.Body).Decode(&call)
+		switch call.Type {
+		case "GET_MASTER":
+			_, _ = fmt.Fprintf(w, `{"type": "GET_MASTER", "get_master": {"master_info": {"pid": "master@%s"}}}`, leaderAddr)
+		case "GET_STATE":
+			_, _ = fmt.Fprint(w, `{"type": "GET_STATE", "get_state": {"get_frameworks": {"frameworks": [{"framework_info": {"id": {"value": "F1"}, "name": "marathon"}}]}}}`)
+		default:
+			http.Error(w, "unsupported call", http.StatusBadRequest)
+		}
+	}))
+	leader.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	leader.StartTLS()
+	defer leader.Close()
+	leaderAddr = strings.TrimPrefix(leader.URL, "https://")
+
+	follower := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, leader.URL+r.URL.Path, http.StatusTemporaryRedirect)
+	}))
+	follower.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	follower.StartTLS()
+	defer follower.Close()
+
+	ca := writeFile(t, dir, "ca.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leader.Certificate().Raw}))
+	cert, key := writeKeyPair(t, dir)
+	credentials := writeFile(t, dir, "credentials.json", []byte(`{"principal": "mesos-dns", "secret": "s3cr3t"}`))
+	noPrincipal := writeFile(t, dir, "no-principal.json", []byte(`{"secret": "s3cr3t"}`))
+	malformed := writeFile(t, dir, "malformed.json", []byte(`principal`))
+
+	for i, tt := range []struct {
+		config func(*Config)
+		err    bool
+		auth   string
+	}{
+		{func(c *Config) { c.MesosCredentialsFile = credentials }, false, "Basic bWVzb3MtZG5zOnMzY3IzdA=="},
+		{func(c *Config) { c.MesosAuthToken = "t0k3n" }, false, "Bearer t0k3n"},
+		{func(c *Config) {}, false, ""},
+		{func(c *Config) { c.MesosCACertFile = filepath.Join(dir, "missing.pem") }, true, ""},
+		{func(c *Config) { c.MesosCACertFile = credentials }, true, ""},
+		{func(c *Config) { c.MesosKeyFile = cert }, true, ""},
+		{func(c *Config) { c.MesosCredentialsFile = noPrincipal }, true, ""},
+		{func(c *Config) { c.MesosCredentialsFile = malformed }, true, ""},
+	} {
+		c := NewConfig()
+		c.StateSource = "operator"
+		c.MesosScheme = "https"
+		c.MesosCACertFile, c.MesosCertFile, c.MesosKeyFile = ca, cert, key
+		tt.config(&c)
+
+		c.mesosClient, err = newMasterClient(c)
+		if (err != nil) != tt.err {
+			t.Errorf("test #%d: got err: %v, want err: %t", i, err, tt.err)
+		}
+		if err != nil {
+			continue
+		}
+
+		// the credentials are sent to the follower and, after the redirect,
+		// to the leader
+		mu.Lock()
+		auths = nil
+		mu.Unlock()
+		var rg RecordGenerator
+		sj, err := rg.findMaster(c, "", strings.TrimPrefix(follower.URL, "https://"))
+		if err != nil {
+			t.Errorf("test #%d: unexpected error: %v", i, err)
+			continue
+		}
+		if want := "master@" + leaderAddr; sj.Leader != want {
+			t.Errorf("test #%d: got leader %q, want %q", i, sj.Leader, want)
+		}
+		mu.Lock()
+		for _, auth := range auths {
+			if auth != tt.auth {
+				t.Errorf("test #%d: got authorization %q, want %q", i, auth, tt.auth)
+			}
+		}
+		if len(auths) != 2 {
+			t.Errorf("test #%d: got %d requests to the leader, want 2", i, len(auths))
+		}
+		mu.Unlock()
+	}
+}
+
+func TestMasterClientRedirectToHostname(t *testing.T) {
+	// the leader is advertised by host name, which is never contacted before
+	// the masters listed by IP redirect to it
+	var (
+		mu    sync.Mutex
+		auths []string
+	)
+	leader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		auths = append(auths, r.Header.Get("Authorization"))
+		mu.Unlock()
+
+		var call struct{ Type string }
+		_ = json.NewDecoder(r.Body).Decode(&call)
+		switch call.Type {
+		case "GET_MASTER":
+			_, _ = fmt.Fprintf(w, `{"type": "GET_MASTER", "get_master": {"master_info": {"pid": "master@%s"}}}`, r.Host)
+		case "GET_STATE":
+			_, _ = fmt.Fprint(w, `{"type": "GET_STATE", "get_state": {}}`)
+		default:
+			http.Error(w, "unsupported call", http.StatusBadRequest)
+		}
+	}))
+	defer leader.Close()
+	_, leaderPort, _ := net.SplitHostPort(strings.TrimPrefix(leader.URL, "http://"))
+	leaderURL := "http://" + net.JoinHostPort("localhost", leaderPort)
+
+	follower := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, leaderURL+r.URL.Path, http.StatusTemporaryRedirect)
+	}))
+	defer follower.Close()
+	followerAddr := strings.TrimPrefix(follower.URL, "http://")
+
+	c := NewConfig()
+	c.StateSource = "operator"
+	c.MesosAuthToken = "t0k3n"
+	c.Masters = []string{followerAddr}
+	mc, err := newMasterClient(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rg RecordGenerator
+	if _, err = rg.loadFromOperatorAPI(mc, "127.0.0.1", strings.Split(followerAddr, ":")[1]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(auths) != 2 {
+		t.Errorf("got %d requests to the leader, want 2", len(auths))
+	}
+	for _, auth := range auths {
+		if auth != "Bearer t0k3n" {
+			t.Errorf("got authorization %q, want %q", auth, "Bearer t0k3n")
+		}
+	}
+}
+
+func TestCheckRedirect(t *testing.T) {
+	mc, err := newMasterClient(Config{
+		MesosScheme:    "https",
+		MesosAuthToken: "t0k3n",
+		Masters:        []string{"10.0.0.1:5050", "10.0.0.2:5050"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// redirects only carry the credentials to known masters over the same
+	// scheme
+	for i, tt := range []struct {
+		from, to string
+		auth     string
+	}{
+		{"https://10.0.0.1:5050/api/v1", "https://10.0.0.2:5050/api/v1", "Bearer t0k3n"},
+		{"https://10.0.0.1:5050/api/v1", "https://10.0.0.1:5051/api/v1", "Bearer t0k3n"},
+		{"https://10.0.0.1:5050/api/v1", "https://10.0.0.9:5050/api/v1", ""},
+		{"https://10.0.0.1:5050/api/v1", "https://attacker.example.com/api/v1", ""},
+		{"https://10.0.0.1:5050/api/v1", "http://10.0.0.2:5050/api/v1", ""},
+	} {
+		via, _ := http.NewRequest("POST", tt.from, nil)
+		req, _ := http.NewRequest("POST", tt.to, nil)
+		req.Header.Set("Authorization", "Bearer t0k3n")
+		if err = mc.checkRedirect(req, []*http.Request{via}); err != nil {
+			t.Errorf("test #%d: unexpected error: %v", i, err)
+		}
+		if got := req.Header.Get("Authorization"); got != tt.auth {
+			t.Errorf("test #%d: %s -> %s: got authorization %q, want %q", i, tt.from, tt.to, got, tt.auth)
+		}
+	}
+
+	// masters detected elsewhere and leaders are known
+	mc.trust("10.0.0.3:5050")
+	mc.trustLeader("master@10.0.0.4:5050")
+	for _, host := range []string{"10.0.0.3", "10.0.0.4"} {
+		if !mc.known(host) {
+			t.Errorf("expected %s to be known", host)
+		}
+	}
+
+	// masters requests were sent to are known
+	req, _ := http.NewRequest("POST", "https://10.0.0.9:5050/api/v1", nil)
+	_, _ = mc.do(req.WithContext(canceled()))
+	if !mc.known("10.0.0.9") {
+		t.Error("expected 10.0.0.9 to be known after sending it a request")
+	}
+}
+
+// canceled returns a canceled context, for requests which must not be sent.
+func canceled() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
+
+func TestConfigRedacted(t *testing.T) {
+	// every secret field is redacted
+	c := NewConfig()
+	v := reflect.ValueOf(&c).Elem()
+	var secrets []string
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Tag.Get("secret") == "true" {
+			v.Field(i).SetString("s3cr3t")
+			secrets = append(secrets, v.Type().Field(i).Name)
+		}
+	}
+	if !reflect.DeepEqual(secrets, []string{"MesosAuthToken"}) {
+		t.Errorf("got secret fields %q", secrets)
+	}
+	r := reflect.ValueOf(c.Redacted())
+	for _, name := range secrets {
+		if got := r.FieldByName(name).String(); got != "REDACTED" {
+			t.Errorf("got %s %q, want it redacted", name, got)
+		}
+	}
+	if c.MesosAuthToken != "s3cr3t" {
+		t.Errorf("redacting changed the config")
+	}
+	if got := NewConfig().Redacted().MesosAuthToken; got != "" {
+		t.Errorf("got token %q of config without token", got)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net"
 	"os/user"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"time"
 
@@ -20,6 +21,41 @@ type Config struct {
 	// Mesos master(s): a list of IP:port pairs for one or more Mesos masters
 	Masters []string
 
+	// StateSource is the API the state of the masters is loaded from:
+	// "state.json" for the legacy /master/state.json endpoint, "operator"
+	// for the GET_STATE call of the v1 operator API, which falls back to
+	// state.json if it fails, or "subscribe" for the event stream of the
+	// SUBSCRIBE call of the v1 operator API, which updates the records as
+	// soon as the state changes instead of every RefreshSeconds
+	// (default "state.json")
+	StateSource string
+
+	// MesosScheme is the scheme of the requests to the masters: "http" or
+	// "https" (default "http")
+	MesosScheme string
+
+	// MesosCACertFile is the path to a PEM file of the certificate
+	// authorities trusted to sign the certificates of the masters, instead of
+	// those of the system
+	MesosCACertFile string
+
+	// MesosCertFile and MesosKeyFile are the paths to the PEM files of the
+	// certificate and key presented to masters requiring client certificates
+	MesosCertFile string
+	MesosKeyFile  string
+
+	// MesosCredentialsFile is the path to a JSON file holding the principal
+	// and secret used for HTTP basic authentication with the masters, e.g.
+	// {"principal": "mesos-dns", "secret": "..."}
+	MesosCredentialsFile string
+
+	// MesosAuthToken is the token sent as a bearer token to the masters.
+	// Fields tagged as secret are redacted from /v1/config.
+	MesosAuthToken string `secret:"true"`
+
+	// mesosClient sends the requests to the masters, with the above settings
+	mesosClient *masterClient
+
 	// Zookeeper: a single Zk url
 	Zk string
 
@@ -29,6 +65,25 @@ type Config struct {
 	// Refresh frequency: the frequency in seconds of regenerating records (default 60)
 	RefreshSeconds int
 
+	// HostCacheTTL is the number of seconds for which the addresses of the
+	// hostnames of frameworks and slaves are cached across refreshes
+	// (default 300)
+	HostCacheTTL int
+
+	// HostCacheNegativeTTL is the number of seconds after which hostnames
+	// which failed to resolve are looked up again. They keep their last known
+	// addresses meanwhile (default 30)
+	HostCacheNegativeTTL int
+
+	// HostLookupConcurrency is the maximum number of hostnames looked up
+	// concurrently on refresh (default 16)
+	HostLookupConcurrency int
+
+	// AgentHostnameIPv6 enables looking up the hostnames of slaves whose PID
+	// holds an IPv4 address for the IPv6 addresses of dual-stack slaves
+	// (default false)
+	AgentHostnameIPv6 bool
+
 	// TTL: the TTL value used for SRV and A records (default 60)
 	TTL int32
 
@@ -57,6 +112,11 @@ type Config struct {
 	SOAExpire  uint32 // expiration time
 	SOAMinttl  uint32 // minimum TTL
 
+	// Zones lists authoritative zones served in addition to Domain, e.g.
+	// aliases of Domain during migrations or zones holding the records of
+	// some frameworks only
+	Zones []Zone
+
 	// Value of RecursionAvailable for responses in Mesos domain
 	RecurseOn bool
 
@@ -85,32 +145,134 @@ type Config struct {
 
 	// IPSources is the prioritized list of task IP sources
 	IPSources []string // e.g. ["host", "docker", "mesos", "rkt"]
+
+	// FrameworkTTLs maps framework names to the TTL of their records and the
+	// records of their tasks, overriding TTL. Tasks can override it in turn
+	// with a MESOS_DNS_TTL label.
+	FrameworkTTLs map[string]int32
+
+	// TaskIDHashLength is the number of characters, between 1 and 13, of the
+	// base32 encoded task ID hash in canonical task names (default 8)
+	TaskIDHashLength int
+
+	// LegacyTaskNames enables serving the canonical task names with decimal
+	// 17-bit task ID hashes of earlier versions along with the current ones
+	LegacyTaskNames bool
+
+	// NamingTemplates holds the templates of the record names of tasks,
+	// frameworks, agents and masters; see NamingTemplates for the variables
+	NamingTemplates NamingTemplates
+
+	// NamesLabel is the key of the task label listing additional names of a
+	// task, separated by commas, e.g. "DNS_NAMES". Names are relative to the
+	// domain or fully qualified within it. Disabled if empty.
+	NamesLabel string
+
+	// SRVPriorityLabel and SRVWeightLabel are the keys of the task labels
+	// holding the priority and weight of the SRV records of tasks, e.g. to
+	// direct a share of the traffic of a service to canary tasks (defaults
+	// "MESOS_DNS_SRV_PRIORITY" and "MESOS_DNS_SRV_WEIGHT"). Tasks without
+	// these labels have priority and weight 0. Disabled if empty.
+	SRVPriorityLabel string
+	SRVWeightLabel   string
+
+	// AppIDLabel is the key of the task label holding the Marathon app ID of
+	// tasks, e.g. "MARATHON_APP_ID". Tasks of apps in groups, such as
+	// /payments/api/v2, are then also published under hierarchical names,
+	// e.g. v2.api.payments.marathon.domain., with SRV records at each level
+	// of their group. Disabled if empty.
+	AppIDLabel string
+
+	// TaskWildcards enables wildcard records, e.g. *.task.framework.domain.,
+	// resolving any name below a task's name to the task
+	TaskWildcards bool
+
+	// ClusterCIDRs is the list of networks whose clients are within the
+	// cluster, e.g. ["10.0.0.0/8"]. Clients outside of these networks don't
+	// resolve the records of tasks with CLUSTER visibility. If empty, all
+	// clients are considered to be within the cluster.
+	ClusterCIDRs []string
+
+	// TaskStates is the list of states of the tasks which are published, e.g.
+	// ["TASK_STARTING", "TASK_RUNNING", "TASK_KILLING"] (default
+	// ["TASK_RUNNING"]). Tasks are withdrawn as soon as they leave these
+	// states, so leaving out TASK_KILLING drains tasks while they shut down.
+	TaskStates []string
+
+	// UnreachableGracePeriod is the number of seconds for which tasks keep
+	// being published after becoming TASK_UNREACHABLE (default 0)
+	UnreachableGracePeriod int
+
+	// HealthCheckMode controls how the health of tasks affects their records:
+	// "ignore" publishes all running tasks, "prefer-healthy" orders the A,
+	// AAAA and SRV answers of healthy tasks first and "healthy-only" only
+	// publishes tasks which pass their health checks (default "ignore")
+	HealthCheckMode string
+
+	// TXTLabels is the allowlist of task label keys which are published along
+	// with the DiscoveryInfo version and environment in the TXT records of
+	// tasks, e.g. ["team", "canary"]
+	TXTLabels []string
+
+	// ReverseZones is the list of CIDRs for which PTR queries are answered
+	// authoritatively, e.g. ["10.0.0.0/8", "fd00::/8"]. PTR queries outside
+	// of these are forwarded to the configured resolvers.
+	ReverseZones []string
+}
+
+// Zone is an authoritative zone served in addition to the one of Domain.
+type Zone struct {
+	// Domain is the name of the zone, e.g. "mesos.corp.example"
+	Domain string
+
+	// SOA record fields of the zone, defaulting to those of Domain
+	SOAMname   string
+	SOARname   string
+	SOARefresh uint32
+	SOARetry   uint32
+	SOAExpire  uint32
+
+	// Frameworks restricts the zone to the framework and task records of the
+	// frameworks of the given names. If empty, the zone is an alias of Domain
+	// holding all of its records.
+	Frameworks []string
 }
 
 // NewConfig return the default config of the resolver
 func NewConfig() Config {
 	return Config{
-		ZkDetectionTimeout: 30,
-		RefreshSeconds:     60,
-		TTL:                60,
-		Domain:             "mesos",
-		Port:               53,
-		Timeout:            5,
-		SOARname:           "root.ns1.mesos",
-		SOAMname:           "ns1.mesos",
-		SOARefresh:         60,
-		SOARetry:           600,
-		SOAExpire:          86400,
-		SOAMinttl:          60,
-		Resolvers:          []string{"8.8.8.8"},
-		Listener:           "0.0.0.0",
-		HTTPPort:           8123,
-		DNSOn:              true,
-		HTTPOn:             true,
-		ExternalOn:         true,
-		RecurseOn:          true,
-		IPSources:          []string{"netinfo", "mesos", "host"},
-		StaticEntryFile:    "",
+		ZkDetectionTimeout:    30,
+		RefreshSeconds:        60,
+		StateSource:           "state.json",
+		MesosScheme:           "http",
+		HostCacheTTL:          300,
+		HostCacheNegativeTTL:  30,
+		HostLookupConcurrency: 16,
+		TTL:                   60,
+		Domain:                "mesos",
+		Port:                  53,
+		Timeout:               5,
+		SOARname:              "root.ns1.mesos",
+		SOAMname:              "ns1.mesos",
+		SOARefresh:            60,
+		SOARetry:              600,
+		SOAExpire:             86400,
+		SOAMinttl:             60,
+		Resolvers:             []string{"8.8.8.8"},
+		Listener:              "0.0.0.0",
+		HTTPPort:              8123,
+		DNSOn:                 true,
+		HTTPOn:                true,
+		ExternalOn:            true,
+		RecurseOn:             true,
+		IPSources:             []string{"netinfo", "mesos", "host"},
+		TaskStates:            []string{"TASK_RUNNING"},
+		SRVPriorityLabel:      "MESOS_DNS_SRV_PRIORITY",
+		SRVWeightLabel:        "MESOS_DNS_SRV_WEIGHT",
+		HealthCheckMode:       "ignore",
+		TaskIDHashLength:      8,
+		NamingTemplates:       DefaultNamingTemplates(),
+		StaticEntryFile:       "",
 	}
 }
 
@@ -145,19 +307,93 @@ func SetConfig(cjson string) Config {
 		logging.Error.Fatalf("IPSources validation failed: %v", err)
 	}
 
+	if err = validateReverseZones(c.ReverseZones); err != nil {
+		logging.Error.Fatalf("ReverseZones validation failed: %v", err)
+	}
+
+	if err = validateFrameworkTTLs(c.FrameworkTTLs); err != nil {
+		logging.Error.Fatalf("FrameworkTTLs validation failed: %v", err)
+	}
+
+	if err = validateTaskIDHashLength(c.TaskIDHashLength); err != nil {
+		logging.Error.Fatalf("TaskIDHashLength validation failed: %v", err)
+	}
+
+	if err = validateNamingTemplates(c.NamingTemplates); err != nil {
+		logging.Error.Fatalf("NamingTemplates validation failed: %v", err)
+	}
+
+	if err = validateClusterCIDRs(c.ClusterCIDRs); err != nil {
+		logging.Error.Fatalf("ClusterCIDRs validation failed: %v", err)
+	}
+
+	if err = validateStateSource(c.StateSource); err != nil {
+		logging.Error.Fatalf("StateSource validation failed: %v", err)
+	}
+
+	if err = validateMesosClient(c); err != nil {
+		logging.Error.Fatalf("Mesos client validation failed: %v", err)
+	}
+	if c.mesosClient, err = newMasterClient(*c); err != nil {
+		logging.Error.Fatalf("Mesos client validation failed: %v", err)
+	}
+
+	if err = validateTaskStates(c.TaskStates); err != nil {
+		logging.Error.Fatalf("TaskStates validation failed: %v", err)
+	}
+
+	if c.UnreachableGracePeriod < 0 {
+		logging.Error.Fatalf("UnreachableGracePeriod validation failed: negative grace period %d", c.UnreachableGracePeriod)
+	}
+
+	if c.HostCacheTTL < 0 || c.HostCacheNegativeTTL < 0 {
+		logging.Error.Fatalf("HostCacheTTL validation failed: negative TTL %d/%d", c.HostCacheTTL, c.HostCacheNegativeTTL)
+	}
+
+	if c.HostLookupConcurrency < 1 {
+		logging.Error.Fatalf("HostLookupConcurrency validation failed: %d is less than 1", c.HostLookupConcurrency)
+	}
+
+	if err = validateHealthCheckMode(c.HealthCheckMode); err != nil {
+		logging.Error.Fatalf("HealthCheckMode validation failed: %v", err)
+	}
+
+	if err = validateTXTLabels(c.TXTLabels); err != nil {
+		logging.Error.Fatalf("TXTLabels validation failed: %v", err)
+	}
+
 	c.Domain = strings.ToLower(c.Domain)
 
+	if err = validateZones(c.Domain, c.Zones); err != nil {
+		logging.Error.Fatalf("Zones validation failed: %v", err)
+	}
+
 	// SOA record fields
 	c.SOARname = strings.TrimRight(strings.Replace(c.SOARname, "@", ".", -1), ".") + "."
 	c.SOAMname = strings.TrimRight(c.SOAMname, ".") + "."
 	c.SOASerial = uint32(time.Now().Unix())
 
+	for i := range c.Zones {
+		c.Zones[i] = c.completeZone(c.Zones[i])
+	}
+
 	// print configuration file
 	logging.Verbose.Println("Mesos-DNS configuration:")
 	logging.Verbose.Println("   - Masters: " + strings.Join(c.Masters, ", "))
 	logging.Verbose.Println("   - Zookeeper: ", c.Zk)
 	logging.Verbose.Println("   - ZookeeperDetectionTimeout: ", c.ZkDetectionTimeout)
 	logging.Verbose.Println("   - RefreshSeconds: ", c.RefreshSeconds)
+	logging.Verbose.Println("   - StateSource: ", c.StateSource)
+	logging.Verbose.Println("   - MesosScheme: ", c.MesosScheme)
+	logging.Verbose.Println("   - MesosCACertFile: ", c.MesosCACertFile)
+	logging.Verbose.Println("   - MesosCertFile: ", c.MesosCertFile)
+	logging.Verbose.Println("   - MesosKeyFile: ", c.MesosKeyFile)
+	logging.Verbose.Println("   - MesosCredentialsFile: ", c.MesosCredentialsFile)
+	logging.Verbose.Println("   - MesosAuthToken: ", c.MesosAuthToken != "")
+	logging.Verbose.Println("   - HostCacheTTL: ", c.HostCacheTTL)
+	logging.Verbose.Println("   - HostCacheNegativeTTL: ", c.HostCacheNegativeTTL)
+	logging.Verbose.Println("   - HostLookupConcurrency: ", c.HostLookupConcurrency)
+	logging.Verbose.Println("   - AgentHostnameIPv6: ", c.AgentHostnameIPv6)
 	logging.Verbose.Println("   - Domain: " + c.Domain)
 	logging.Verbose.Println("   - Listener: " + c.Listener)
 	logging.Verbose.Println("   - Port: ", c.Port)
@@ -173,6 +409,7 @@ func SetConfig(cjson string) Config {
 	logging.Verbose.Println("   - SOARetry: ", c.SOARetry)
 	logging.Verbose.Println("   - SOAExpire: ", c.SOAExpire)
 	logging.Verbose.Println("   - SOAExpire: ", c.SOAMinttl)
+	logging.Verbose.Printf("   - Zones: %+v", c.Zones)
 	logging.Verbose.Println("   - RecurseOn: ", c.RecurseOn)
 	logging.Verbose.Println("   - HttpPort: ", c.HTTPPort)
 	logging.Verbose.Println("   - HttpOn: ", c.HTTPOn)
@@ -180,6 +417,21 @@ func SetConfig(cjson string) Config {
 	logging.Verbose.Println("   - EnforceRFC952: ", c.EnforceRFC952)
 	logging.Verbose.Println("   - IPSources: ", c.IPSources)
 	logging.Verbose.Println("   - StaticEntryFile: ", c.StaticEntryFile)
+	logging.Verbose.Println("   - ReverseZones: ", c.ReverseZones)
+	logging.Verbose.Println("   - FrameworkTTLs: ", c.FrameworkTTLs)
+	logging.Verbose.Println("   - TaskIDHashLength: ", c.TaskIDHashLength)
+	logging.Verbose.Println("   - LegacyTaskNames: ", c.LegacyTaskNames)
+	logging.Verbose.Printf("   - NamingTemplates: %+v", c.NamingTemplates)
+	logging.Verbose.Println("   - NamesLabel: ", c.NamesLabel)
+	logging.Verbose.Println("   - SRVPriorityLabel: ", c.SRVPriorityLabel)
+	logging.Verbose.Println("   - SRVWeightLabel: ", c.SRVWeightLabel)
+	logging.Verbose.Println("   - AppIDLabel: ", c.AppIDLabel)
+	logging.Verbose.Println("   - TaskWildcards: ", c.TaskWildcards)
+	logging.Verbose.Println("   - ClusterCIDRs: ", c.ClusterCIDRs)
+	logging.Verbose.Println("   - TaskStates: ", c.TaskStates)
+	logging.Verbose.Println("   - UnreachableGracePeriod: ", c.UnreachableGracePeriod)
+	logging.Verbose.Println("   - HealthCheckMode: ", c.HealthCheckMode)
+	logging.Verbose.Println("   - TXTLabels: ", c.TXTLabels)
 
 	return *c
 }
@@ -275,3 +527,85 @@ func localAddies() []string {
 
 	return bad
 }
+
+// Redacted returns the config without its secrets, to be shown to users: the
+// values of the string fields tagged as secret are replaced.
+func (c Config) Redacted() Config {
+	v := reflect.ValueOf(&c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		if v.Type().Field(i).Tag.Get("secret") == "true" && f.Kind() == reflect.String && f.String() != "" {
+			f.SetString("REDACTED")
+		}
+	}
+	return c
+}
+
+// masterClient returns the client of the requests to the masters, which
+// sends plain HTTP requests unless the config was loaded by SetConfig.
+func (c Config) masterClient() *masterClient {
+	if c.mesosClient == nil {
+		return defaultMasterClient
+	}
+	return c.mesosClient
+}
+
+// TrustMasters adds the masters at the given addresses, e.g. those detected
+// in ZooKeeper, to the masters which redirects may send the credentials to.
+func (c Config) TrustMasters(masters ...string) {
+	c.masterClient().trust(masters...)
+}
+
+// completeZone returns the given Zone with its domain name normalized and its
+// unset SOA record fields set to those of Domain.
+func (c Config) completeZone(z Zone) Zone {
+	z.Domain = strings.ToLower(strings.TrimRight(z.Domain, "."))
+	if z.SOAMname == "" {
+		z.SOAMname = c.SOAMname
+	}
+	if z.SOARname == "" {
+		z.SOARname = c.SOARname
+	}
+	z.SOARname = strings.TrimRight(strings.Replace(z.SOARname, "@", ".", -1), ".") + "."
+	z.SOAMname = strings.TrimRight(z.SOAMname, ".") + "."
+	if z.SOARefresh == 0 {
+		z.SOARefresh = c.SOARefresh
+	}
+	if z.SOARetry == 0 {
+		z.SOARetry = c.SOARetry
+	}
+	if z.SOAExpire == 0 {
+		z.SOAExpire = c.SOAExpire
+	}
+	return z
+}
+
+// ZoneOf returns the authoritative zone of the given name: the one of the
+// longest matching configured Zone or else the one of Domain.
+func (c Config) ZoneOf(name string) Zone {
+	zone := Zone{
+		Domain:     c.Domain,
+		SOAMname:   c.SOAMname,
+		SOARname:   c.SOARname,
+		SOARefresh: c.SOARefresh,
+		SOARetry:   c.SOARetry,
+		SOAExpire:  c.SOAExpire,
+	}
+	name = strings.ToLower(strings.TrimRight(name, ".")) + "."
+	match := 0
+	if inZone(name, c.Domain) {
+		match = len(c.Domain)
+	}
+	for _, z := range c.Zones {
+		if inZone(name, z.Domain) && len(z.Domain) > match {
+			zone, match = z, len(z.Domain)
+		}
+	}
+	return zone
+}
+
+// inZone returns true if the given fully qualified name is within the zone of
+// the given domain.
+func inZone(name, domain string) bool {
+	return name == domain+"." || strings.HasSuffix(name, "."+domain+".")
+}
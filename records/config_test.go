@@ -39,3 +39,34 @@ func TestNewConfigValidates(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestZoneOf(t *testing.T) {
+	c := NewConfig()
+	c.SOARname = "ns1.mesos."
+	c.Zones = []Zone{
+		c.completeZone(Zone{Domain: "Mesos.Corp.Example.", SOARname: "ns1.corp.example", SOARetry: 300}),
+		c.completeZone(Zone{Domain: "team.mesos"}),
+	}
+
+	for i, tt := range []struct {
+		name   string
+		domain string
+	}{
+		{"mesos.", "mesos"},
+		{"nginx.marathon.mesos.", "mesos"},
+		{"nginx.marathon.mesos.corp.example.", "mesos.corp.example"},
+		{"mesos.corp.example.", "mesos.corp.example"},
+		{"nginx.marathon.team.mesos.", "team.mesos"},
+		{"nginx.marathon.steam.mesos.", "mesos"},
+		{"1.0.0.10.in-addr.arpa.", "mesos"},
+	} {
+		if got := c.ZoneOf(tt.name).Domain; got != tt.domain {
+			t.Errorf("test #%d: %q: got %q, want %q", i, tt.name, got, tt.domain)
+		}
+	}
+
+	z := c.ZoneOf("mesos.corp.example.")
+	if z.SOARname != "ns1.corp.example." || z.SOAMname != c.SOAMname+"." || z.SOARetry != 300 || z.SOARefresh != c.SOARefresh {
+		t.Errorf("unexpected SOA fields of zone: %+v", z)
+	}
+}
@@ -0,0 +1,79 @@
+package records
+
+import "sort"
+
+// Op is the kind of a Change.
+type Op string
+
+// Change operations.
+const (
+	Add    Op = "add"
+	Remove Op = "remove"
+)
+
+// Change is a record which was added or removed between two record sets.
+type Change struct {
+	Op     Op     `json:"op"`
+	Name   string `json:"name"`
+	Record Record `json:"record"`
+}
+
+// Diff returns the changes from the records of old to those of rg, sorted by
+// name, with removals first. Records are compared by their data only, so
+// changes of e.g. TTLs or health alone aren't reported.
+func (rg *RecordGenerator) Diff(old *RecordGenerator) []Change {
+	var changes []Change
+	for _, name := range mergeNames(old.Names(), rg.Names()) {
+		for _, rtype := range RecordTypes {
+			before, after := old.Records(name, rtype), rg.Records(name, rtype)
+			for _, rr := range before {
+				if !containsData(after, rr) {
+					changes = append(changes, Change{Remove, name, rr})
+				}
+			}
+			for _, rr := range after {
+				if !containsData(before, rr) {
+					changes = append(changes, Change{Add, name, rr})
+				}
+			}
+		}
+	}
+	return changes
+}
+
+// Summary returns the number of added and removed records of the given
+// changes.
+func Summary(changes []Change) (added, removed int) {
+	for _, c := range changes {
+		if c.Op == Add {
+			added++
+		} else {
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// containsData returns whether any of the given records holds the same data as
+// the given record.
+func containsData(rrs []Record, rr Record) bool {
+	for _, r := range rrs {
+		if r.sameData(rr) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeNames returns the sorted union of the given sorted names.
+func mergeNames(a, b []string) []string {
+	names := make([]string, 0, len(a)+len(b))
+	names = append(names, a...)
+	for _, name := range b {
+		if i := sort.SearchStrings(a, name); i == len(a) || a[i] != name {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
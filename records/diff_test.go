@@ -0,0 +1,47 @@
+package records
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	var old, cur RecordGenerator
+	old.resetRecords()
+	cur.resetRecords()
+
+	old.insertIP("web.marathon.mesos.", "10.0.0.1", Record{TTL: 60})
+	old.insertIP("web.marathon.mesos.", "10.0.0.2", Record{})
+	old.insertSRV("_web._tcp.marathon.mesos.", "web-1.marathon.mesos.", "80", Record{})
+	old.insertIP("db.marathon.mesos.", "10.0.0.9", Record{})
+
+	// TTL changes alone aren't reported
+	cur.insertIP("web.marathon.mesos.", "10.0.0.1", Record{TTL: 5})
+	cur.insertIP("web.marathon.mesos.", "10.0.0.3", Record{})
+	cur.insertSRV("_web._tcp.marathon.mesos.", "web-1.marathon.mesos.", "80", Record{})
+	cur.insertSRV("_web._tcp.marathon.mesos.", "web-3.marathon.mesos.", "80", Record{})
+
+	want := []Change{
+		{Add, "_web._tcp.marathon.mesos.", Record{Type: "SRV", Target: "web-3.marathon.mesos.", Port: 80}},
+		{Remove, "db.marathon.mesos.", Record{Type: "A", Target: "10.0.0.9"}},
+		{Remove, "web.marathon.mesos.", Record{Type: "A", Target: "10.0.0.2"}},
+		{Add, "web.marathon.mesos.", Record{Type: "A", Target: "10.0.0.3"}},
+	}
+	got := cur.Diff(&old)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if added, removed := Summary(got); added != 2 || removed != 2 {
+		t.Errorf("got %d added and %d removed, want 2 and 2", added, removed)
+	}
+
+	if got := cur.Diff(&cur); len(got) != 0 {
+		t.Errorf("expected no changes, got %+v", got)
+	}
+
+	// the first record set is all new
+	var empty RecordGenerator
+	if added, removed := Summary(cur.Diff(&empty)); added != 4 || removed != 0 {
+		t.Errorf("got %d added and %d removed, want 4 and 0", added, removed)
+	}
+}
@@ -3,49 +3,73 @@
 package records
 
 import (
+	"bytes"
+	"encoding/base32"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"hash/fnv"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
-	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mesosphere/mesos-dns/logging"
 	"github.com/mesosphere/mesos-dns/records/labels"
 	"github.com/mesosphere/mesos-dns/records/state"
+	"github.com/miekg/dns"
 )
 
 // Map host/service name to DNS answer
-// REFACTOR - when discoveryinfo is integrated
-// Will likely become map[string][]discoveryinfo
-type rrs map[string][]string
+type rrs map[string][]Record
 
 // RecordGenerator contains DNS records and methods to access and manipulate
 // them. TODO(kozyraki): Refactor when discovery id is available.
 type RecordGenerator struct {
 	As       rrs
+	AAAAs    rrs
 	SRVs     rrs
-	SlaveIPs map[string]string
+	PTRs     rrs
+	CNAMEs   rrs
+	TXTs     rrs
+	MXs      rrs
+	NSs      rrs
+	SlaveIPs map[string][]string
+
+	// HostCache, if set, caches the addresses of the hostnames of frameworks
+	// and slaves across reloads
+	HostCache *HostCache
+
+	// taskNames maps canonical task names to the IDs of the tasks owning them
+	taskNames map[string]string
+	// external holds the records visible to clients outside of the cluster
+	external *RecordGenerator
+	// ancestors holds the names with names of records below them, which
+	// exist as per RFC 4592 even without records of their own
+	ancestors map[string]bool
 }
 
-// ParseState retrieves and parses the Mesos master /state.json and converts it
-// into DNS records.
+// RecordTypes lists the types of records a RecordGenerator stores.
+var RecordTypes = []string{"A", "AAAA", "SRV", "PTR", "CNAME", "TXT", "MX", "NS"}
+
+// ParseState retrieves and parses the state of the Mesos master, from the
+// source given by the config, and converts it into DNS records.
 func (rg *RecordGenerator) ParseState(c Config, masters ...string) error {
 	// find master -- return if error
-	sj, err := rg.findMaster(masters...)
+	sj, err := rg.findMaster(c, masters...)
 	if err != nil {
 		logging.Error.Println("no master")
 		if rg.As == nil {
-			rg.As = rrs{}
-		}
-		if rg.SRVs == nil {
-			rg.SRVs = rrs{}
+			rg.resetRecords()
 		}
 		rg.staticRecords(c.StaticEntryConfig.Entries)
+		rg.dropConflictingCNAMEs()
+		rg.indexNames()
 		return err
 	}
 	if sj.Leader == "" {
@@ -54,19 +78,14 @@ func (rg *RecordGenerator) ParseState(c Config, masters ...string) error {
 		return err
 	}
 
-	hostSpec := labels.RFC1123
-	if c.EnforceRFC952 {
-		hostSpec = labels.RFC952
-	}
-
 	// insert state
-	rg.InsertState(sj, c.Domain, c.SOARname, c.Listener, masters, c.IPSources, c.StaticEntryConfig.Entries, hostSpec)
+	rg.InsertState(sj, c, masters)
 	return nil
 }
 
 // Tries each master and looks for the leader
 // if no leader responds it errors
-func (rg *RecordGenerator) findMaster(masters ...string) (state.State, error) {
+func (rg *RecordGenerator) findMaster(c Config, masters ...string) (state.State, error) {
 	var sj state.State
 	var leader string
 
@@ -82,7 +101,7 @@ func (rg *RecordGenerator) findMaster(masters ...string) (state.State, error) {
 			logging.Error.Println(err)
 		}
 
-		sj, _ = rg.loadWrap(ip, port)
+		sj, _ = rg.loadWrap(c, ip, port)
 		if sj.Leader != "" {
 			return sj, nil
 		}
@@ -100,7 +119,7 @@ func (rg *RecordGenerator) findMaster(masters ...string) (state.State, error) {
 			logging.Error.Println(err)
 		}
 
-		sj, _ = rg.loadWrap(ip, port)
+		sj, _ = rg.loadWrap(c, ip, port)
 		if sj.Leader == "" {
 			logging.VeryVerbose.Println("Warning: not a leader - trying next one")
 			if len(masters)-1 == i {
@@ -117,24 +136,15 @@ func (rg *RecordGenerator) findMaster(masters ...string) (state.State, error) {
 }
 
 // Loads state.json from mesos master
-func (rg *RecordGenerator) loadFromMaster(ip string, port string) (sj state.State) {
-	// REFACTOR: state.json security
-
-	u := url.URL{
-		Scheme: "http",
-		Host:   net.JoinHostPort(ip, port),
-		Path:   "/master/state.json",
-	}
-
-	req, err := http.NewRequest("GET", u.String(), nil)
+func (rg *RecordGenerator) loadFromMaster(mc *masterClient, ip string, port string) (sj state.State) {
+	req, err := http.NewRequest("GET", mc.url(net.JoinHostPort(ip, port), "/master/state.json"), nil)
 	if err != nil {
 		logging.Error.Println(err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := mc.do(req)
 	if err != nil {
 		logging.Error.Println(err)
 	}
@@ -149,15 +159,80 @@ func (rg *RecordGenerator) loadFromMaster(ip string, port string) (sj state.Stat
 	if err != nil {
 		logging.Error.Println(err)
 	}
+	mc.trustLeader(sj.Leader)
 
 	return sj
 }
 
-// Catches an attempt to load state.json from a mesos master
+// operatorRequest returns a request of the given call to the v1 operator API
+// of the Mesos master at the given address.
+func (mc *masterClient) operatorRequest(addr, call string) (*http.Request, error) {
+	req, err := http.NewRequest("POST", mc.url(addr, "/api/v1"), strings.NewReader(`{"type":"`+call+`"}`))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	return req, nil
+}
+
+// operatorError returns the error of an unsuccessful response to the given
+// call of the v1 operator API.
+func operatorError(call, addr string, resp *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("%s call to %s: %s: %s", call, addr, resp.Status, bytes.TrimSpace(body))
+}
+
+// operatorCall calls the v1 operator API of the Mesos master at the given
+// address and returns the response body along with the address of the master
+// which answered, which is the leader if the call was redirected.
+func (mc *masterClient) operatorCall(addr, call string) ([]byte, string, error) {
+	req, err := mc.operatorRequest(addr, call)
+	if err != nil {
+		return nil, "", err
+	}
+
+	resp, err := mc.do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", operatorError(call, addr, resp)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, resp.Request.URL.Host, nil
+}
+
+// Loads the state from the v1 operator API of the mesos master. Non-leading
+// masters redirect to the leader, whose state is loaded.
+func (rg *RecordGenerator) loadFromOperatorAPI(mc *masterClient, ip string, port string) (state.State, error) {
+	body, leaderAddr, err := mc.operatorCall(net.JoinHostPort(ip, port), "GET_MASTER")
+	if err != nil {
+		return state.State{}, err
+	}
+	leader, err := state.ParseOperatorMaster(body)
+	if err != nil {
+		return state.State{}, err
+	}
+	mc.trustLeader(leader)
+
+	if body, _, err = mc.operatorCall(leaderAddr, "GET_STATE"); err != nil {
+		return state.State{}, err
+	}
+	return state.ParseOperatorState(body, leader)
+}
+
+// Catches an attempt to load the state from a mesos master
 // attempts can fail from down server or mesos master secondary
 // it also reloads from a different master if the master it attempted to
-// load from was not the leader
-func (rg *RecordGenerator) loadWrap(ip string, port string) (state.State, error) {
+// load from was not the leader. The operator API sources fall back to
+// state.json if the operator API fails, e.g. on masters predating it.
+func (rg *RecordGenerator) loadWrap(c Config, ip string, port string) (state.State, error) {
 	var err error
 	var sj state.State
 
@@ -169,20 +244,47 @@ func (rg *RecordGenerator) loadWrap(ip string, port string) (state.State, error)
 	}()
 
 	logging.VeryVerbose.Println("reloading from master " + ip)
-	sj = rg.loadFromMaster(ip, port)
+	mc := c.masterClient()
+	if c.StateSource == "operator" || c.StateSource == "subscribe" {
+		osj, oerr := rg.loadFromOperatorAPI(mc, ip, port)
+		if oerr == nil {
+			return osj, nil
+		}
+		logging.Error.Printf("cannot load state from operator API of master %s, falling back to state.json: %v", ip, oerr)
+	}
+	sj = rg.loadFromMaster(mc, ip, port)
 
 	if rip := leaderIP(sj.Leader); rip != ip {
 		logging.VeryVerbose.Println("Warning: master changed to " + ip)
-		sj = rg.loadFromMaster(rip, port)
+		sj = rg.loadFromMaster(mc, rip, port)
 	}
 
 	return sj, err
 }
 
-// BUG: The probability of hashing collisions is too high with only 17 bits.
-// NOTE: Using a numerical base as high as valid characters in DNS names would
-// reduce the resulting length without risking more collisions.
-func hashString(s string) string {
+// maxHashLength is the length of the base32 encoding of a 64-bit hash.
+const maxHashLength = 13
+
+// hashEncoding is the lower case base32 encoding used for hashes in names.
+var hashEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567")
+
+// hashString returns the first n characters, at most maxHashLength, of the
+// base32 encoded 64-bit FNV-1a hash of s.
+func hashString(s string, n int) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	var sum [8]byte
+	binary.BigEndian.PutUint64(sum[:], h.Sum64())
+	if n > maxHashLength {
+		n = maxHashLength
+	}
+	return hashEncoding.EncodeToString(sum[:])[:n]
+}
+
+// legacyHashString returns the decimal 17-bit hash of s used in canonical
+// task names by earlier versions. Its probability of collisions is too high
+// for large clusters; it's only kept for compatibility.
+func legacyHashString(s string) string {
 	h := fnv.New32a()
 	_, _ = h.Write([]byte(s))
 	sum := h.Sum32()
@@ -190,79 +292,250 @@ func hashString(s string) string {
 	return strconv.FormatUint(uint64(lower+upper), 10)
 }
 
-// attempt to translate the hostname into an IPv4 address. logs an error if IP
-// lookup fails. if an IP address cannot be found, returns the same hostname
-// that was given. upon success returns the IP address as a string.
-func hostToIP4(hostname string) (string, bool) {
-	ip := net.ParseIP(hostname)
-	if ip == nil {
-		t, err := net.ResolveIPAddr("ip4", hostname)
-		if err != nil {
-			logging.Error.Printf("cannot translate hostname %q into an ip4 address", hostname)
-			return hostname, false
+// lookupIP looks up the IP addresses of a host. It's a variable for tests.
+var lookupIP = net.LookupIP
+
+// timeNow returns the current time. It's a variable for tests.
+var timeNow = time.Now
+
+// attempt to translate the hostname into its IPv4 and IPv6 addresses, through
+// the HostCache if there's one. logs an error if IP lookup fails. upon success
+// returns the IP addresses as strings.
+func (rg *RecordGenerator) hostToIPs(hostname string) ([]string, bool) {
+	if rg.HostCache != nil {
+		return rg.HostCache.Lookup(hostname)
+	}
+	if ip := net.ParseIP(hostname); ip != nil {
+		return []string{ip.String()}, true
+	}
+	addrs, err := lookupAddrs(hostname)
+	if err != nil {
+		logging.Error.Printf("cannot translate hostname %q into an ip address", hostname)
+		return nil, false
+	}
+	return addrs, true
+}
+
+// lookupAddrs looks up the IP addresses of the given hostname.
+func lookupAddrs(hostname string) ([]string, error) {
+	ips, err := lookupIP(hostname)
+	if err != nil {
+		return nil, err
+	} else if len(ips) == 0 {
+		return nil, errors.New("no addresses")
+	}
+	addrs := make([]string, len(ips))
+	for i := range ips {
+		addrs[i] = ips[i].String()
+	}
+	return addrs, nil
+}
+
+// ipType returns the record type used to store the given address: "AAAA" for
+// IPv6 addresses and "A" for anything else.
+func ipType(addr string) string {
+	if ip := net.ParseIP(addr); ip != nil && ip.To4() == nil {
+		return "AAAA"
+	}
+	return "A"
+}
+
+// taskIPs returns the first IPv4 and the first IPv6 address of the given task
+// found in the highest priority IP source that yields any address.
+func taskIPs(task *state.Task, srcs []string) []string {
+	for _, src := range srcs {
+		var v4, v6 string
+		for _, ip := range task.IPs(src) {
+			if ip.To4() != nil {
+				if v4 == "" {
+					v4 = ip.String()
+				}
+			} else if v6 == "" {
+				v6 = ip.String()
+			}
 		}
-		ip = t.IP
+		if v4 == "" && v6 == "" {
+			continue
+		}
+		ips := make([]string, 0, 2)
+		for _, ip := range [...]string{v4, v6} {
+			if ip != "" {
+				ips = append(ips, ip)
+			}
+		}
+		return ips
 	}
-	return ip.String(), true
+	return nil
 }
 
-// InsertState transforms a StateJSON into RecordGenerator RRs
-func (rg *RecordGenerator) InsertState(sj state.State, domain string, ns string, listener string, masters []string, ipSources []string, staticEntries []StaticEntry, spec labels.Func) error {
+// InsertState transforms a StateJSON into RecordGenerator RRs as configured
+// in the given Config.
+func (rg *RecordGenerator) InsertState(sj state.State, c Config, masters []string) error {
+	spec := labels.RFC1123
+	if c.EnforceRFC952 {
+		spec = labels.RFC952
+	}
 
-	rg.SlaveIPs = map[string]string{}
-	rg.SRVs = rrs{}
-	rg.As = rrs{}
-	rg.frameworkRecords(sj, domain, spec)
-	rg.slaveRecords(sj, domain, spec)
-	rg.listenerRecord(listener, ns)
-	rg.masterRecord(domain, masters, sj.Leader)
-	rg.taskRecords(sj, domain, spec, ipSources)
-	rg.staticRecords(staticEntries)
+	rg.SlaveIPs = map[string][]string{}
+	rg.taskNames = map[string]string{}
+	rg.resetRecords()
+	if rg.HostCache != nil {
+		rg.HostCache.Resolve(stateHostnames(sj, c.AgentHostnameIPv6))
+	}
+	rg.frameworkRecords(sj, c, spec)
+	rg.slaveRecords(sj, c, spec)
+	rg.listenerRecord(c.Listener, c.SOARname)
+	rg.masterRecord(c.NamingTemplates, c.Domain, masters, sj.Leader)
+	aliases := rg.taskRecords(sj, c, spec)
+	rg.staticRecords(c.StaticEntryConfig.Entries)
+	rg.aliasRecords(aliases, c.Domain, spec)
+	rg.dropConflictingCNAMEs()
+	rg.zoneRecords(sj, c)
+	rg.indexNames()
+	rg.external = rg.filter(func(rr Record) bool {
+		return rr.Visibility != "CLUSTER"
+	})
 
 	return nil
 }
 
-// frameworkRecords injects A and SRV records into the generator store:
+// frameworkRecords injects A, AAAA and SRV records into the generator store:
 //     frameworkname.domain.                 // resolves to IPs of each framework
 //     _framework._tcp.frameworkname.domain. // resolves to the driver port and IP of each framework
-func (rg *RecordGenerator) frameworkRecords(sj state.State, domain string, spec labels.Func) {
+func (rg *RecordGenerator) frameworkRecords(sj state.State, c Config, spec labels.Func) {
 	for _, f := range sj.Frameworks {
 		fname := labels.DomainFrag(f.Name, labels.Sep, spec)
 		host, port := f.HostPort()
-		if address, ok := hostToIP4(host); ok {
-			a := fname + "." + domain + "."
-			rg.insertRR(a, address, "A")
+		origin := Record{
+			Class:       FrameworkClass,
+			FrameworkID: f.ID,
+			TTL:         uint32(c.FrameworkTTLs[f.Name]),
+		}
+		if addresses, ok := rg.hostToIPs(host); ok {
+			a, err := qualifiedName(c.NamingTemplates.Framework, c.Domain, map[string]string{"framework": fname})
+			if err != nil {
+				logging.Error.Printf("no records for framework %q: %v", f.Name, err)
+				continue
+			}
+			for _, address := range addresses {
+				rg.insertIP(a, address, origin)
+			}
 			if port != "" {
-				srv := net.JoinHostPort(a, port)
-				rg.insertRR("_framework._tcp."+a, srv, "SRV")
+				rg.insertSRV("_framework._tcp."+a, a, port, origin)
 			}
 		}
 	}
 }
 
-// slaveRecords injects A and SRV records into the generator store:
-//     slave.domain.      // resolves to IPs of all slaves
-//     _slave._tc.domain. // resolves to the driver port and IP of all slaves
-func (rg *RecordGenerator) slaveRecords(sj state.State, domain string, spec labels.Func) {
+// slaveRecords injects A, AAAA, PTR and SRV records into the generator store:
+//     slave.domain.                    // resolves to IPs of all slaves
+//     _slave._tc.domain.               // resolves to the driver port and IP of all slaves
+//     <reverse ip>.                    // resolves to the first name of each slave below
+//     <slave-id>.slave.domain.         // resolves to IPs of each slave
+//     _slave._tcp.<slave-id>.slave.domain.
+//     <hostname>.agent.domain.         // resolves to IPs of each slave
+//     _agent._tcp.<hostname>.agent.domain.
+func (rg *RecordGenerator) slaveRecords(sj state.State, c Config, spec labels.Func) {
+	owners := map[string]string{}
+	a := clusterName(c.NamingTemplates, c.Domain, "slave")
+	srv := clusterName(c.NamingTemplates, c.Domain, "_slave._tcp")
 	for _, slave := range sj.Slaves {
-		address, ok := hostToIP4(slave.PID.Host)
+		origin := Record{Class: SlaveClass, SlaveID: slave.ID}
+		addresses, ok := rg.slaveIPs(slave, c.AgentHostnameIPv6)
 		if ok {
-			a := "slave." + domain + "."
-			rg.insertRR(a, address, "A")
-			srv := net.JoinHostPort(a, slave.PID.Port)
-			rg.insertRR("_slave._tcp."+domain+".", srv, "SRV")
+			for _, address := range addresses {
+				rg.insertIP(a, address, origin)
+			}
+			rg.insertSRV(srv, a, slave.PID.Port, origin)
+
+			// reverse lookups identify the slave by its own name, falling
+			// back to the shared one if it has none
+			ptr := a
+			for _, name := range slaveNames(slave, c.NamingTemplates, c.Domain, spec) {
+				if owner, taken := owners[name.host]; taken && owner != slave.ID {
+					logging.Error.Printf("name %q of slave %q is taken by slave %q", name.host, slave.ID, owner)
+					continue
+				}
+				owners[name.host] = slave.ID
+				for _, address := range addresses {
+					rg.insertIP(name.host, address, origin)
+				}
+				rg.insertSRV(name.srv, name.host, slave.PID.Port, origin)
+				if ptr == a {
+					ptr = name.host
+				}
+			}
+			for _, address := range addresses {
+				rg.insertPTR(address, ptr, origin)
+			}
 		} else {
-			logging.VeryVerbose.Printf("string '%q' for slave with id %q is not a valid IP address", address, slave.ID)
-			address = labels.DomainFrag(address, labels.Sep, spec)
+			logging.VeryVerbose.Printf("string '%q' for slave with id %q is not a valid IP address", slave.PID.Host, slave.ID)
+			addresses = []string{labels.DomainFrag(slave.PID.Host, labels.Sep, spec)}
+		}
+		rg.SlaveIPs[slave.ID] = addresses
+	}
+}
+
+// slaveName holds a host name of an individual slave and the name of its SRV
+// record.
+type slaveName struct{ host, srv string }
+
+// slaveNames returns the host and service names of an individual slave.
+// Slave IDs are mangled as RFC 1123 labels regardless of the given spec since
+// they always start with digits, which RFC 952 doesn't allow.
+func slaveNames(slave state.Slave, tmpls NamingTemplates, domain string, spec labels.Func) []slaveName {
+	vars := map[string]string{
+		"agent-id": labels.RFC1123(slave.ID),
+		"hostname": labels.DomainFrag(slave.Hostname, labels.Sep, spec),
+	}
+
+	var names []slaveName
+	for _, t := range []struct{ tmpl, srv string }{
+		{tmpls.Agent, "_slave._tcp."},
+		{tmpls.AgentHost, "_agent._tcp."},
+	} {
+		host, err := qualifiedName(t.tmpl, domain, vars)
+		if err != nil {
+			logging.VeryVerbose.Printf("no name for slave %q: %v", slave.ID, err)
+			continue
 		}
-		rg.SlaveIPs[slave.ID] = address
+		names = append(names, slaveName{host, t.srv + host})
 	}
+	return names
 }
 
-// masterRecord injects A and SRV records into the generator store:
+// slaveIPs returns the addresses of the given slave. The PID host is
+// authoritative; when it's an IPv4 address and the hostname is looked up, any
+// IPv6 addresses the slave's hostname resolves to are added so that
+// dual-stack slaves get AAAA records.
+func (rg *RecordGenerator) slaveIPs(slave state.Slave, lookupHostname bool) ([]string, bool) {
+	addresses, ok := rg.hostToIPs(slave.PID.Host)
+	if !ok || !lookupHostname || slave.Hostname == "" || slave.Hostname == slave.PID.Host {
+		return addresses, ok
+	}
+	for _, address := range addresses {
+		if ipType(address) == "AAAA" {
+			return addresses, ok
+		}
+	}
+	if net.ParseIP(slave.Hostname) != nil {
+		return addresses, ok
+	}
+	if extra, found := rg.hostToIPs(slave.Hostname); found {
+		for _, address := range extra {
+			if ipType(address) == "AAAA" {
+				addresses = append(addresses, address)
+			}
+		}
+	}
+	return addresses, ok
+}
+
+// masterRecord injects A, AAAA, PTR and SRV records into the generator store:
 //     master.domain.  // resolves to IPs of all masters
 //     masterN.domain. // one IP address for each master
 //     leader.domain.  // one IP address for the leading master
+//     <reverse ip>.   // resolves to masterN.domain. of each master
 //
 // The current func implementation makes an assumption about the order of masters:
 // it's the order in which you expect the enumerated masterN records to be created.
@@ -285,7 +558,7 @@ func (rg *RecordGenerator) slaveRecords(sj state.State, domain string, spec labe
 // So the func tries to index the masters as they're listed and begrudgingly assigns
 // the leading master an index out-of-band if it's not actually listed in the masters
 // list. There are probably better ways to do it.
-func (rg *RecordGenerator) masterRecord(domain string, masters []string, leader string) {
+func (rg *RecordGenerator) masterRecord(tmpls NamingTemplates, domain string, masters []string, leader string) {
 	// create records for leader
 	// A records
 	h := strings.Split(leader, "@")
@@ -299,17 +572,18 @@ func (rg *RecordGenerator) masterRecord(domain string, masters []string, leader
 		logging.Error.Println(err)
 		return
 	}
-	arec := "leader." + domain + "."
-	rg.insertRR(arec, ip, "A")
-	arec = "master." + domain + "."
-	rg.insertRR(arec, ip, "A")
+	origin := Record{Class: MasterClass}
+	arec := clusterName(tmpls, domain, "leader")
+	rg.insertIP(arec, ip, origin)
+	arec = clusterName(tmpls, domain, "master")
+	rg.insertIP(arec, ip, origin)
 
 	// SRV records
-	tcp := "_leader._tcp." + domain + "."
-	udp := "_leader._udp." + domain + "."
-	host := "leader." + domain + "." + ":" + port
-	rg.insertRR(tcp, host, "SRV")
-	rg.insertRR(udp, host, "SRV")
+	tcp := clusterName(tmpls, domain, "_leader._tcp")
+	udp := clusterName(tmpls, domain, "_leader._udp")
+	host := clusterName(tmpls, domain, "leader")
+	rg.insertSRV(tcp, host, port, origin)
+	rg.insertSRV(udp, host, port, origin)
 
 	// if there is a list of masters, insert that as well
 	addedLeaderMasterN := false
@@ -324,8 +598,8 @@ func (rg *RecordGenerator) masterRecord(domain string, masters []string, leader
 
 		// A records (master and masterN)
 		if master != leaderAddress {
-			arec := "master." + domain + "."
-			added := rg.insertRR(arec, ip, "A")
+			arec := clusterName(tmpls, domain, "master")
+			added := rg.insertIP(arec, ip, origin)
 			if !added {
 				// duplicate master?!
 				continue
@@ -337,8 +611,9 @@ func (rg *RecordGenerator) masterRecord(domain string, masters []string, leader
 			continue
 		}
 
-		arec := "master" + strconv.Itoa(idx) + "." + domain + "."
-		rg.insertRR(arec, ip, "A")
+		arec := clusterName(tmpls, domain, "master"+strconv.Itoa(idx))
+		rg.insertIP(arec, ip, origin)
+		rg.insertPTR(ip, arec, origin)
 		idx++
 
 		if master == leaderAddress {
@@ -351,44 +626,144 @@ func (rg *RecordGenerator) masterRecord(domain string, masters []string, leader
 		if len(masters) > 0 {
 			logging.Error.Printf("warning: leader %q is not in master list", leader)
 		}
-		arec = "master" + strconv.Itoa(idx) + "." + domain + "."
-		rg.insertRR(arec, ip, "A")
+		arec = clusterName(tmpls, domain, "master"+strconv.Itoa(idx))
+		rg.insertIP(arec, ip, origin)
+		rg.insertPTR(ip, arec, origin)
+	}
+}
+
+// zoneRecords copies the records within Domain, except for PTR records, into
+// each of the configured Zones, renaming the names and targets within Domain.
+// Zones restricted to some frameworks only get the framework and task records
+// of those.
+func (rg *RecordGenerator) zoneRecords(sj state.State, c Config) {
+	var zones []*RecordGenerator
+	for _, z := range c.Zones {
+		keep := func(Record) bool { return true }
+		if len(z.Frameworks) > 0 {
+			ids := map[string]bool{}
+			for _, f := range sj.Frameworks {
+				if contains(z.Frameworks, f.Name) {
+					ids[f.ID] = true
+				}
+			}
+			keep = func(rr Record) bool {
+				return (rr.Class == TaskClass || rr.Class == FrameworkClass) && ids[rr.FrameworkID]
+			}
+		}
+
+		domain := z.Domain
+		rename := func(name string) string {
+			if !inZone(name, c.Domain) {
+				return name
+			}
+			return strings.TrimSuffix(name, c.Domain+".") + domain + "."
+		}
+
+		zone := &RecordGenerator{}
+		zone.resetRecords()
+		for _, rtype := range RecordTypes {
+			if rtype == "PTR" {
+				continue
+			}
+			for name, rrs := range rg.store(rtype) {
+				if !inZone(name, c.Domain) {
+					continue
+				}
+				for _, rr := range rrs {
+					if !keep(rr) {
+						continue
+					}
+					switch rtype {
+					case "SRV", "CNAME", "MX", "NS":
+						rr.Target = rename(rr.Target)
+					}
+					zone.insertRR(rename(name), rr)
+				}
+			}
+		}
+		zones = append(zones, zone)
+
+		// the name server of the zone
+		if z.SOARname != "" && z.SOARname != c.SOARname {
+			zone.listenerRecord(c.Listener, z.SOARname)
+		}
+	}
+
+	for _, zone := range zones {
+		for _, rtype := range RecordTypes {
+			for name, rrs := range zone.store(rtype) {
+				for _, rr := range rrs {
+					rg.insertRR(name, rr)
+				}
+			}
+		}
 	}
 }
 
-// A record for mesos-dns (the name is listed in SOA replies)
+// A and AAAA records for mesos-dns (the name is listed in SOA replies)
 func (rg *RecordGenerator) listenerRecord(listener string, ns string) {
-	if listener == "0.0.0.0" {
+	if listener == "0.0.0.0" || listener == "::" {
 		rg.setFromLocal(listener, ns)
-	} else if listener == "127.0.0.1" {
-		rg.insertRR(ns, "127.0.0.1", "A")
 	} else {
-		rg.insertRR(ns, listener, "A")
+		rg.insertIP(ns, listener, Record{Class: ListenerClass})
 	}
 }
 
-func (rg *RecordGenerator) taskRecords(sj state.State, domain string, spec labels.Func, ipSources []string) {
+// taskRecords injects the records of all tasks in published states into the
+// generator store and returns the additional names of tasks given by their
+// NamesLabel.
+func (rg *RecordGenerator) taskRecords(sj state.State, c Config, spec labels.Func) []taskAlias {
+	var aliases []taskAlias
+	now := timeNow()
 	for _, f := range sj.Frameworks {
 		fname := labels.DomainFrag(f.Name, labels.Sep, spec)
 
+		// parent of the hierarchical names of Marathon app groups
+		groupTail, err := qualifiedName(c.NamingTemplates.Framework, c.Domain, map[string]string{"framework": fname})
+		if err != nil {
+			groupTail = ""
+		}
+
+		tasks := f.Tasks
+		if len(f.UnreachableTasks) > 0 {
+			tasks = append(tasks[:len(tasks):len(tasks)], f.UnreachableTasks...)
+		}
+
 		// insert taks records
-		tail := "." + domain + "."
-		for _, task := range f.Tasks {
+		tail := "." + c.Domain + "."
+		for _, task := range tasks {
 			var ok bool
-			task.SlaveIP, ok = rg.SlaveIPs[task.SlaveID]
+			task.SlaveIPs, ok = rg.SlaveIPs[task.SlaveID]
+
+			// skip tasks in unpublished states and not discoverable ones.
+			// unreachable agents leave the state before their tasks do.
+			if !publishable(&task, c, now) || (!ok && task.State != "TASK_UNREACHABLE") {
+				continue
+			}
 
-			// skip not running or not discoverable tasks
-			if !ok || (task.State != "TASK_RUNNING") {
+			// skip tasks which are only visible to their framework
+			visibility := strings.ToUpper(task.DiscoveryInfo.Visibilty)
+			if visibility == "FRAMEWORK" {
+				logging.VeryVerbose.Printf("skipping task %q with FRAMEWORK visibility", task.ID)
+				continue
+			}
+
+			// skip unhealthy tasks if only healthy ones are to be published
+			healthy := task.Healthy()
+			if !healthy && c.HealthCheckMode == "healthy-only" {
 				continue
 			}
 
 			// define context
-			ctx := struct{ taskName, taskID, slaveID, taskIP, slaveIP string }{
+			ctx := struct {
+				taskName, slaveID string
+				taskIPs, slaveIPs []string
+			}{
 				spec(task.Name),
-				hashString(task.ID),
 				slaveIDTail(task.SlaveID),
-				task.IP(ipSources...),
-				task.SlaveIP,
+				taskIPs(&task, c.IPSources),
+				task.SlaveIPs,
 			}
 
 			// use DiscoveryInfo name if defined instead of task name
@@ -396,30 +771,132 @@ func (rg *RecordGenerator) taskRecords(sj state.State, domain string, spec label
 				ctx.taskName = task.DiscoveryInfo.Name
 			}
 
+			origin := Record{
+				Class:       TaskClass,
+				TaskID:      task.ID,
+				FrameworkID: task.FrameworkID,
+				SlaveID:     task.SlaveID,
+				Visibility:  visibility,
+				Unhealthy:   !healthy,
+				TTL:         taskTTL(&task, uint32(c.FrameworkTTLs[f.Name])),
+			}
+
+			// SRV records carry the priority and weight given by the task's labels
+			srv := origin
+			srv.Priority = labelUint16(&task, c.SRVPriorityLabel)
+			srv.Weight = labelUint16(&task, c.SRVWeightLabel)
+
+			// malformed port ranges only cost the task the SRV records of
+			// their ports
+			ports, err := task.Resources.Ports()
+			if err != nil {
+				logging.Error.Printf("invalid port resources of task %q: %v", task.ID, err)
+			}
+
+			// render the task's names from the naming templates
+			vars := taskVars(&task, ctx.taskName, fname, spec)
+			arec, err := render(c.NamingTemplates.Task, vars)
+			if err != nil {
+				logging.VeryVerbose.Printf("no records for task %q: %v", task.ID, err)
+				continue
+			}
+			onSlave := func(name string) string {
+				return slaveAddressName(c.NamingTemplates, c.Domain, name)
+			}
+			rename := func(taskName string) string {
+				name, _ := render(c.NamingTemplates.Task, withVars(vars, "task", taskName))
+				return name
+			}
+
 			// insert canonical A records
-			canonical := ctx.taskName + "-" + ctx.taskID + "-" + ctx.slaveID + "." + fname
-			arec := ctx.taskName + "." + fname
+			canonical := rg.canonicalName(task.ID, c.TaskIDHashLength, func(hash string) string {
+				return rename(ctx.taskName + "-" + hash + "-" + ctx.slaveID)
+			})
+
+			// keep serving the legacy canonical names during migrations
+			var legacy string
+			if c.LegacyTaskNames {
+				legacy = rename(ctx.taskName + "-" + legacyHashString(task.ID) + "-" + ctx.slaveID)
+				if !rg.claimTaskName(legacy, task.ID) {
+					legacy = ""
+				}
+			}
+			if legacy != "" {
+				for _, ip := range ctx.taskIPs {
+					rg.insertIP(legacy+tail, ip, origin)
+				}
+				for _, ip := range ctx.slaveIPs {
+					rg.insertIP(onSlave(legacy), ip, origin)
+				}
+			}
 
-			rg.insertRR(arec+tail, ctx.taskIP, "A")
-			rg.insertRR(canonical+tail, ctx.taskIP, "A")
+			for _, ip := range ctx.taskIPs {
+				rg.insertIP(arec+tail, ip, origin)
+				rg.insertIP(canonical+tail, ip, origin)
+
+				// container IPs resolve back to the task, slave IPs to the slave
+				if !contains(ctx.slaveIPs, ip) {
+					rg.insertPTR(ip, canonical+tail, origin)
+				}
+			}
 
-			rg.insertRR(arec+".slave"+tail, ctx.slaveIP, "A")
-			rg.insertRR(canonical+".slave"+tail, ctx.slaveIP, "A")
+			for _, ip := range ctx.slaveIPs {
+				rg.insertIP(onSlave(arec), ip, origin)
+				rg.insertIP(onSlave(canonical), ip, origin)
+			}
 
-			// Add RFC 2782 SRV records
-			slaveHost := canonical + ".slave" + tail
-			tcpName := "_" + ctx.taskName + "._tcp." + fname
-			udpName := "_" + ctx.taskName + "._udp." + fname
-			for _, port := range task.Ports() {
-				slaveTarget := slaveHost + ":" + port
+			// any name below the task's name resolves to the task, e.g. for
+			// virtual hosts of HTTP routers
+			if c.TaskWildcards {
+				for _, ip := range ctx.taskIPs {
+					rg.insertIP("*."+arec+tail, ip, origin)
+				}
+			}
 
+			// additional names are inserted once all generated names are known
+			if c.NamesLabel != "" {
+				for _, name := range strings.Split(labelValue(&task, c.NamesLabel), ",") {
+					if name = strings.TrimSpace(name); name != "" {
+						aliases = append(aliases, taskAlias{name, ctx.taskIPs, origin, f.ID + "/" + ctx.taskName})
+					}
+				}
+			}
+
+			// tasks of apps in Marathon groups are also published under the
+			// hierarchical names of their app IDs
+			slaveHost := onSlave(canonical)
+			if c.AppIDLabel != "" && groupTail != "" {
+				if appID := labelValue(&task, c.AppIDLabel); appID != "" {
+					rg.groupRecords(&task, appID, groupTail, canonical+tail, slaveHost, ctx.taskIPs, ports, origin, srv, spec)
+				}
+			}
+
+			// insert TXT records with the task's metadata
+			for _, txt := range taskTXT(&task, c.TXTLabels) {
+				rr := origin
+				rr.Type, rr.Target = "TXT", txt
+				rg.insertRR(arec+tail, rr)
+				rg.insertRR(canonical+tail, rr)
+			}
+
+			// Add RFC 2782 SRV records
+			var tcpName, udpName string
+			tcpName, err = render(c.NamingTemplates.TaskService, withVars(vars, "protocol", "tcp"))
+			if err == nil {
+				udpName, err = render(c.NamingTemplates.TaskService, withVars(vars, "protocol", "udp"))
+			}
+			if err != nil {
+				logging.VeryVerbose.Printf("no SRV records for task %q: %v", task.ID, err)
+				continue
+			}
+			for _, port := range ports {
 				if !task.HasDiscoveryInfo() {
-					rg.insertRR(tcpName+tail, slaveTarget, "SRV")
-					rg.insertRR(udpName+tail, slaveTarget, "SRV")
+					rg.insertSRV(tcpName+tail, slaveHost, port, srv)
+					rg.insertSRV(udpName+tail, slaveHost, port, srv)
 				}
 
-				rg.insertRR(tcpName+".slave"+tail, slaveTarget, "SRV")
-				rg.insertRR(udpName+".slave"+tail, slaveTarget, "SRV")
+				rg.insertSRV(onSlave(tcpName), slaveHost, port, srv)
+				rg.insertSRV(onSlave(udpName), slaveHost, port, srv)
 			}
 
 			if !task.HasDiscoveryInfo() {
@@ -427,29 +904,355 @@ func (rg *RecordGenerator) taskRecords(sj state.State, domain string, spec label
 			}
 
 			for _, port := range task.DiscoveryInfo.Ports.DiscoveryPorts {
-				target := canonical + tail + ":" + strconv.Itoa(port.Number)
+				target := canonical + tail
+				number := strconv.Itoa(port.Number)
+				rr := srv
+				rr.PortName = port.Name
 
 				// use protocol if defined, fallback to tcp+udp
-				proto := spec(port.Protocol)
-				if proto != "" {
-					name := "_" + ctx.taskName + "._" + proto + "." + fname
-					rg.insertRR(name+tail, target, "SRV")
-				} else {
-					rg.insertRR(tcpName+tail, target, "SRV")
-					rg.insertRR(udpName+tail, target, "SRV")
+				protos := []string{"tcp", "udp"}
+				if proto := spec(port.Protocol); proto != "" {
+					protos = []string{proto}
+				}
+
+				for _, proto := range protos {
+					pvars := withVars(vars, "protocol", proto)
+					if name, err := render(c.NamingTemplates.TaskService, pvars); err == nil {
+						rg.insertSRV(name+tail, target, number, rr)
+					}
+
+					// named ports get their own RFC 2782 service name, e.g.
+					// _http._task._tcp.framework.domain.
+					if portName := spec(port.Name); portName != "" {
+						name, err := render(c.NamingTemplates.PortService, withVars(pvars, "port", portName))
+						if err == nil {
+							rg.insertSRV(name+tail, target, number, rr)
+						}
+					}
 				}
 			}
 		}
 	}
+	return aliases
+}
+
+// groupRecords injects the records of a task under the hierarchical name of
+// its Marathon app ID below the given framework name, along with SRV records
+// at each level of the app's group, e.g. for the app /payments/api/v2:
+//     v2.api.payments.marathon.domain.       // resolves to the IPs of the task
+//     _v2._tcp.api.payments.marathon.domain. // resolves to the tasks of the app
+//     _api._tcp.payments.marathon.domain.    // resolves to the tasks of the group
+//     _payments._tcp.marathon.domain.        // resolves to the tasks of the group
+// Apps outside of groups are left to the regular task records. SRV records
+// point at the given target, or the given slaveHost for tasks without
+// DiscoveryInfo, and are inserted for the given ports with the given srv
+// origin, which carries their priority and weight.
+func (rg *RecordGenerator) groupRecords(task *state.Task, appID, fqdn, target, slaveHost string, ips, ports []string, origin, srv Record, spec labels.Func) {
+	path := strings.Split(strings.Trim(appID, "/"), "/")
+	if len(path) < 2 {
+		return
+	}
+
+	// names[i] is the hierarchical name of the group or app path[:i+1]
+	names := make([]string, len(path))
+	parent := fqdn
+	for i := range path {
+		if path[i] = spec(path[i]); path[i] == "" {
+			logging.VeryVerbose.Printf("no group records for task %q: invalid app ID %q", task.ID, appID)
+			return
+		}
+		names[i] = path[i] + "." + parent
+		parent = names[i]
+	}
+
+	for _, ip := range ips {
+		rg.insertIP(names[len(names)-1], ip, origin)
+	}
+
+	// the SRV records of each level point at the same targets as the task's
+	// own SRV records
+	for i := range path {
+		parent := fqdn
+		if i > 0 {
+			parent = names[i-1]
+		}
+		if !task.HasDiscoveryInfo() {
+			for _, port := range ports {
+				rg.insertSRV("_"+path[i]+"._tcp."+parent, slaveHost, port, srv)
+				rg.insertSRV("_"+path[i]+"._udp."+parent, slaveHost, port, srv)
+			}
+			continue
+		}
+		for _, port := range task.DiscoveryInfo.Ports.DiscoveryPorts {
+			rr := srv
+			rr.PortName = port.Name
+			protos := []string{"tcp", "udp"}
+			if proto := spec(port.Protocol); proto != "" {
+				protos = []string{proto}
+			}
+			for _, proto := range protos {
+				rg.insertSRV("_"+path[i]+"._"+proto+"."+parent, target, strconv.Itoa(port.Number), rr)
+			}
+		}
+	}
+}
+
+// taskAlias is an additional name of a task, given by its NamesLabel.
+type taskAlias struct {
+	name   string
+	ips    []string
+	origin Record
+	owner  string // framework ID and name of the task
+}
+
+// aliasRecords injects A and AAAA records for the additional names of tasks
+// unless they're invalid or taken by generated records or by other tasks.
+// Tasks of the same name and framework, e.g. the instances of an app, share
+// their additional names.
+func (rg *RecordGenerator) aliasRecords(aliases []taskAlias, domain string, spec labels.Func) {
+	owners := map[string]string{}
+	for _, a := range aliases {
+		name, err := aliasName(a.name, domain, spec)
+		if err != nil {
+			logging.Error.Printf("invalid name of task %q: %v", a.origin.TaskID, err)
+			continue
+		}
+		if owner, ok := owners[name]; ok && owner != a.owner {
+			logging.Error.Printf("name %q of task %q is taken by another task", name, a.origin.TaskID)
+			continue
+		} else if !ok && rg.Contains(name) {
+			logging.Error.Printf("name %q of task %q conflicts with generated records", name, a.origin.TaskID)
+			continue
+		}
+		owners[name] = a.owner
+		for _, ip := range a.ips {
+			rg.insertIP(name, ip, a.origin)
+		}
+	}
+}
+
+// aliasName returns the fully qualified form of an additional task name, which
+// is either relative to the domain or fully qualified within it. All its
+// labels must be valid as per the given spec.
+func aliasName(name, domain string, spec labels.Func) (string, error) {
+	name = strings.ToLower(name)
+	if strings.HasSuffix(name, ".") {
+		if name == domain+"." || !inZone(name, domain) {
+			return "", fmt.Errorf("%q is not below domain %q", name, domain)
+		}
+		name = strings.TrimSuffix(name, "."+domain+".")
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || spec(label) != label {
+			return "", fmt.Errorf("%q has invalid label %q", name, label)
+		}
+	}
+	return name + "." + domain + ".", nil
+}
+
+// canonicalName returns the canonical name of a task, as returned by the given
+// name func for the hash of the task ID of the given length, e.g.
+// <task>-<hash>-<slave>.<framework>. On collisions with the names of other
+// tasks, the hash is extended or, if at its maximum length, suffixed with a
+// sequence number.
+func (rg *RecordGenerator) canonicalName(taskID string, hashLen int, name func(hash string) string) string {
+	first := name(hashString(taskID, hashLen))
+	if rg.claimTaskName(first, taskID) {
+		return first
+	}
+
+	for n := hashLen + 1; n <= maxHashLength; n++ {
+		if canonical := name(hashString(taskID, n)); rg.claimTaskName(canonical, taskID) {
+			logging.Error.Printf("canonical name %q of task %q is taken by task %q; using %q",
+				first, taskID, rg.taskNames[first], canonical)
+			return canonical
+		}
+	}
+
+	hash := hashString(taskID, maxHashLength)
+	for i := 1; ; i++ {
+		if canonical := name(hash + strconv.Itoa(i)); rg.claimTaskName(canonical, taskID) {
+			logging.Error.Printf("canonical name %q of task %q is taken by task %q; using %q",
+				first, taskID, rg.taskNames[first], canonical)
+			return canonical
+		}
+	}
+}
+
+// claimTaskName claims the given canonical name for a task. returns false if
+// the name is already claimed by another task.
+func (rg *RecordGenerator) claimTaskName(name, taskID string) bool {
+	if rg.taskNames == nil {
+		rg.taskNames = map[string]string{}
+	}
+	if owner, ok := rg.taskNames[name]; ok && owner != taskID {
+		return false
+	}
+	rg.taskNames[name] = taskID
+	return true
+}
+
+// TTLLabel is the key of the task label which overrides the TTL of the
+// task's records.
+const TTLLabel = "MESOS_DNS_TTL"
+
+// publishable returns whether the records of a task in its current state are
+// published at the given time: either its state is one of the TaskStates or
+// it became unreachable within the UnreachableGracePeriod.
+func publishable(task *state.Task, c Config, now time.Time) bool {
+	if contains(c.TaskStates, task.State) {
+		return true
+	}
+	if task.State != "TASK_UNREACHABLE" || c.UnreachableGracePeriod <= 0 {
+		return false
+	}
+	since, ok := task.Entered("TASK_UNREACHABLE")
+	return ok && now.Sub(since) < time.Duration(c.UnreachableGracePeriod)*time.Second
+}
+
+// taskTTL returns the TTL given by the TTLLabel of a task, with task labels
+// taking precedence over DiscoveryInfo labels, or the given default if there
+// is none.
+func taskTTL(task *state.Task, def uint32) uint32 {
+	value := labelValue(task, TTLLabel)
+	if value == "" {
+		return def
+	}
+
+	ttl, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || ttl == 0 {
+		logging.Error.Printf("invalid %s label %q of task %q", TTLLabel, value, task.ID)
+		return def
+	}
+	return uint32(ttl)
+}
+
+// labelUint16 returns the value of the task or DiscoveryInfo label of the
+// given key as a 16-bit unsigned integer, or 0 if there's none or it's
+// invalid.
+func labelUint16(task *state.Task, key string) uint16 {
+	if key == "" {
+		return 0
+	}
+	value := labelValue(task, key)
+	if value == "" {
+		return 0
+	}
+
+	n, err := strconv.ParseUint(value, 10, 16)
+	if err != nil {
+		logging.Error.Printf("invalid %s label %q of task %q", key, value, task.ID)
+		return 0
+	}
+	return uint16(n)
+}
+
+// labelValue returns the value of the task or DiscoveryInfo label of the given
+// key, with task labels taking precedence, or "" if there is none.
+func labelValue(task *state.Task, key string) string {
+	var value string
+	for _, l := range task.DiscoveryInfo.Labels.Labels {
+		if l.Key == key {
+			value = l.Value
+		}
+	}
+	for _, l := range task.Labels {
+		if l.Key == key {
+			value = l.Value
+		}
+	}
+	return value
+}
+
+// taskTXT returns the key=value pairs published in the TXT records of a task:
+// its DiscoveryInfo version, environment and location, followed by all its
+// task, DiscoveryInfo and latest status labels whose keys are in the given
+// allowlist.
+func taskTXT(task *state.Task, allowlist []string) []string {
+	var txt []string
+	if v := task.DiscoveryInfo.Version; v != "" {
+		txt = append(txt, "version="+v)
+	}
+	if env := task.DiscoveryInfo.Environment; env != "" {
+		txt = append(txt, "environment="+env)
+	}
+	if loc := task.DiscoveryInfo.Location; loc != "" {
+		txt = append(txt, "location="+loc)
+	}
+
+	add := func(key, value string) {
+		if contains(allowlist, key) {
+			txt = append(txt, key+"="+value)
+		}
+	}
+	for _, l := range task.Labels {
+		add(l.Key, l.Value)
+	}
+	for _, l := range task.DiscoveryInfo.Labels.Labels {
+		add(l.Key, l.Value)
+	}
+	for _, l := range task.StatusLabels() {
+		add(l.Key, l.Value)
+	}
+	return txt
 }
 
 func (rg *RecordGenerator) staticRecords(entries []StaticEntry) {
 	for _, entry := range entries {
-		rg.insertRR(entry.Fqdn, entry.Value, entry.Type)
+		origin := Record{Class: StaticClass, TTL: entry.TTL}
+		name := strings.ToLower(entry.Fqdn)
+		switch entry.Type {
+		case "SRV":
+			host, port, err := net.SplitHostPort(entry.Value)
+			if err != nil {
+				logging.Error.Printf("invalid SRV static entry %q: %v", entry.Value, err)
+				continue
+			}
+			rg.insertSRV(name, host, port, origin)
+		case "MX":
+			pref, host, err := SplitMX(entry.Value)
+			if err != nil {
+				logging.Error.Println(err)
+				continue
+			}
+			rr := origin
+			rr.Type, rr.Target, rr.Priority = entry.Type, host, pref
+			rg.insertRR(name, rr)
+		case "PTR":
+			// PTR entries may be keyed by the IP address they map
+			if net.ParseIP(entry.Fqdn) != nil {
+				rg.insertPTR(entry.Fqdn, entry.Value, origin)
+				continue
+			}
+			fallthrough
+		default:
+			rr := origin
+			rr.Type, rr.Target = entry.Type, entry.Value
+			rg.insertRR(name, rr)
+		}
+	}
+}
+
+// dropConflictingCNAMEs drops the CNAME records of names holding records of
+// other types too, since a CNAME must be the only record of its name (RFC
+// 1034, section 3.6.2), and all but the first CNAME record of every name.
+func (rg *RecordGenerator) dropConflictingCNAMEs() {
+	for name, cnames := range rg.CNAMEs {
+		for _, rtype := range RecordTypes {
+			if rtype != "CNAME" && len(rg.store(rtype)[name]) > 0 {
+				logging.Error.Printf("dropping CNAME of %s, which has %s records too", name, rtype)
+				delete(rg.CNAMEs, name)
+				break
+			}
+		}
+		if len(cnames) > 1 && len(rg.CNAMEs[name]) > 0 {
+			logging.Error.Printf("dropping all but the first CNAME of %s", name)
+			rg.CNAMEs[name] = cnames[:1]
+		}
 	}
 }
 
-// A records for each local interface
+// A and AAAA records for each local interface
 // If this causes problems you should explicitly set the
 // listener address in config.json
 func (rg *RecordGenerator) setFromLocal(host string, ns string) {
@@ -480,65 +1283,242 @@ func (rg *RecordGenerator) setFromLocal(host string, ns string) {
 				continue
 			}
 
-			ip = ip.To4()
-			if ip == nil {
+			// link-local IPv6 addresses are useless without a zone
+			if ip.To4() == nil && ip.IsLinkLocalUnicast() {
 				continue
 			}
 
-			rg.insertRR(ns, ip.String(), "A")
+			rg.insertIP(ns, ip.String(), Record{Class: ListenerClass})
 		}
 	}
 }
 
-func (rg *RecordGenerator) exists(name, host, rtype string) bool {
-	if rtype == "A" {
-		if val, ok := rg.As[name]; ok {
-			// check if A record already exists
-			// identical tasks on same slave
-			for _, b := range val {
-				if b == host {
-					return true
+// External returns the records visible to clients outside of the cluster,
+// i.e. all but those of tasks with CLUSTER visibility.
+func (rg *RecordGenerator) External() *RecordGenerator {
+	if rg.external == nil {
+		return rg
+	}
+	return rg.external
+}
+
+// filter returns a RecordGenerator holding only the records for which keep
+// returns true.
+func (rg *RecordGenerator) filter(keep func(Record) bool) *RecordGenerator {
+	f := &RecordGenerator{SlaveIPs: rg.SlaveIPs, taskNames: rg.taskNames}
+	f.resetRecords()
+	for _, rtype := range RecordTypes {
+		dst := f.store(rtype)
+		for name, rrs := range rg.store(rtype) {
+			for _, rr := range rrs {
+				if keep(rr) {
+					dst[name] = append(dst[name], rr)
 				}
 			}
 		}
-	} else {
-		if val, ok := rg.SRVs[name]; ok {
-			// check if SRV record already exists
-			for _, b := range val {
-				if b == host {
-					return true
+	}
+	f.indexNames()
+	return f
+}
+
+// resetRecords replaces all record maps with empty ones.
+func (rg *RecordGenerator) resetRecords() {
+	rg.As = rrs{}
+	rg.AAAAs = rrs{}
+	rg.SRVs = rrs{}
+	rg.PTRs = rrs{}
+	rg.CNAMEs = rrs{}
+	rg.TXTs = rrs{}
+	rg.MXs = rrs{}
+	rg.NSs = rrs{}
+}
+
+// store returns the record map holding records of the given type, or nil if
+// records of that type aren't supported.
+func (rg *RecordGenerator) store(rtype string) rrs {
+	switch rtype {
+	case "A":
+		return rg.As
+	case "AAAA":
+		return rg.AAAAs
+	case "SRV":
+		return rg.SRVs
+	case "PTR":
+		return rg.PTRs
+	case "CNAME":
+		return rg.CNAMEs
+	case "TXT":
+		return rg.TXTs
+	case "MX":
+		return rg.MXs
+	case "NS":
+		return rg.NSs
+	default:
+		return nil
+	}
+}
+
+// Records returns all records of the given type for the given name.
+func (rg *RecordGenerator) Records(name, rtype string) []Record {
+	return rg.store(rtype)[name]
+}
+
+// Match returns the name under which the records answering for the given name
+// are stored: the name itself if there are records for it, or else the
+// wildcard name of its closest ancestor as per RFC 4592, e.g. "*.apps.mesos."
+// for "web.apps.mesos.". Wildcards don't match existing names or below them,
+// including empty non-terminals. Names matching neither are returned as they
+// are.
+func (rg *RecordGenerator) Match(name string) string {
+	if rg.Exists(name) {
+		return name
+	}
+	for i, end := dns.NextLabel(name, 0); !end; i, end = dns.NextLabel(name, i) {
+		if wild := "*." + name[i:]; rg.Contains(wild) {
+			return wild
+		} else if rg.Exists(name[i:]) {
+			break
+		}
+	}
+	return name
+}
+
+// Exists returns true if the given name exists as per RFC 4592, i.e. if there
+// are records for it or for names below it.
+func (rg *RecordGenerator) Exists(name string) bool {
+	return rg.ancestors[name] || rg.Contains(name)
+}
+
+// indexNames indexes the ancestors of the names of all records, which Exists
+// looks up. It's called once all records are inserted.
+func (rg *RecordGenerator) indexNames() {
+	rg.ancestors = map[string]bool{}
+	for _, rtype := range RecordTypes {
+		for name := range rg.store(rtype) {
+			for i, end := dns.NextLabel(name, 0); !end; i, end = dns.NextLabel(name, i) {
+				if rg.ancestors[name[i:]] {
+					break
 				}
+				rg.ancestors[name[i:]] = true
 			}
 		}
 	}
+}
+
+// Contains returns true if there are records of any type for the given name.
+func (rg *RecordGenerator) Contains(name string) bool {
+	for _, rtype := range RecordTypes {
+		if len(rg.store(rtype)[name]) > 0 {
+			return true
+		}
+	}
 	return false
 }
 
-// insertRR adds a record to the appropriate record map for the given name/host pair,
-// but only if the pair is unique. returns true if added, false otherwise.
-// TODO(???): REFACTOR when storage is updated
-func (rg *RecordGenerator) insertRR(name, host, rtype string) bool {
-	if host == "" || rg.exists(name, host, rtype) {
-		return false
+// Names returns the sorted names of all records.
+func (rg *RecordGenerator) Names() []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, rtype := range RecordTypes {
+		for name := range rg.store(rtype) {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
 	}
+	sort.Strings(names)
+	return names
+}
 
-	logging.VeryVerbose.Println("[" + rtype + "]\t" + name + ": " + host)
+// find returns the index of the record of the given name holding the same data
+// as the given record, or -1 if there's none.
+func (rg *RecordGenerator) find(name string, rr Record) int {
+	// check if the record already exists
+	// e.g. identical tasks on same slave
+	for i, b := range rg.store(rr.Type)[name] {
+		if b.sameData(rr) {
+			return i
+		}
+	}
+	return -1
+}
 
-	if rtype == "A" {
-		val := rg.As[name]
-		rg.As[name] = append(val, host)
-	} else {
-		val := rg.SRVs[name]
-		rg.SRVs[name] = append(val, host)
+// insertRR adds a record to the appropriate record map for the given name,
+// but only if its data is unique; otherwise it's merged into the record with
+// the same data. returns true if added, false otherwise.
+func (rg *RecordGenerator) insertRR(name string, rr Record) bool {
+	store := rg.store(rr.Type)
+	if store == nil {
+		logging.Error.Printf("unsupported record type %q for %q", rr.Type, name)
+		return false
+	}
+	if rr.Target == "" {
+		return false
+	}
+	if i := rg.find(name, rr); i >= 0 {
+		store[name][i] = store[name][i].merge(rr)
+		return false
 	}
+
+	logging.VeryVerbose.Println("[" + rr.Type + "]\t" + name + ": " + rr.String())
+
+	store[name] = append(store[name], rr)
 	return true
 }
 
+// insertIP adds an A or AAAA record, depending on the address family, for the
+// given name/address pair with the given origin. returns true if added, false
+// otherwise.
+func (rg *RecordGenerator) insertIP(name, address string, origin Record) bool {
+	origin.Type, origin.Target = ipType(address), address
+	return rg.insertRR(name, origin)
+}
+
+// insertSRV adds a SRV record for the given name pointing to the given
+// target and port with the given origin. returns true if added, false
+// otherwise.
+func (rg *RecordGenerator) insertSRV(name, target, port string, origin Record) bool {
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		logging.Error.Printf("invalid port %q for SRV record %q", port, name)
+		return false
+	}
+	origin.Type, origin.Target, origin.Port = "SRV", target, uint16(p)
+	return rg.insertRR(name, origin)
+}
+
+// insertPTR adds a PTR record mapping the reverse name of the given address to
+// the given name with the given origin. returns true if added, false
+// otherwise.
+func (rg *RecordGenerator) insertPTR(address, name string, origin Record) bool {
+	arpa, err := dns.ReverseAddr(address)
+	if err != nil {
+		return false
+	}
+	origin.Type, origin.Target = "PTR", name
+	return rg.insertRR(arpa, origin)
+}
+
+// contains returns true if the given string is in the given slice.
+func contains(ss []string, s string) bool {
+	for i := range ss {
+		if ss[i] == s {
+			return true
+		}
+	}
+	return false
+}
+
 // leaderIP returns the ip for the mesos master
 // input format master@ip:port
 func leaderIP(leader string) string {
 	pair := strings.Split(leader, "@")[1]
-	return strings.Split(pair, ":")[0]
+	ip, _, err := getProto(pair)
+	if err != nil {
+		return pair
+	}
+	return ip
 }
 
 // return the slave number from a Mesos slave id
@@ -553,6 +1533,9 @@ func slaveIDTail(slaveID string) string {
 // zk://username:password@host1:port1,host2:port2,.../path
 // file:///path/to/file (where file contains one of the above)
 func getProto(pair string) (string, string, error) {
+	if host, port, err := net.SplitHostPort(pair); err == nil {
+		return host, port, nil
+	}
 	h := strings.SplitN(pair, ":", 2)
 	if len(h) != 2 {
 		return "", "", fmt.Errorf("unable to parse proto from %q", pair)
@@ -2,10 +2,17 @@ package records
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 	"testing/quick"
+	"time"
 
 	"github.com/mesosphere/mesos-dns/logging"
 	"github.com/mesosphere/mesos-dns/records/labels"
@@ -19,7 +26,7 @@ func init() {
 }
 
 func TestMasterRecord(t *testing.T) {
-	// masterRecord(domain string, masters []string, leader string)
+	// masterRecord(tmpls NamingTemplates, domain string, masters []string, leader string)
 	type expectedRR struct {
 		name  string
 		host  string
@@ -41,8 +48,17 @@ func TestMasterRecord(t *testing.T) {
 				{"leader.foo.com.", "6", "A"},
 				{"master.foo.com.", "6", "A"},
 				{"master0.foo.com.", "6", "A"},
-				{"_leader._tcp.foo.com.", "leader.foo.com.:7", "SRV"},
-				{"_leader._udp.foo.com.", "leader.foo.com.:7", "SRV"},
+				{"_leader._tcp.foo.com.", "0 0 7 leader.foo.com.", "SRV"},
+				{"_leader._udp.foo.com.", "0 0 7 leader.foo.com.", "SRV"},
+			}},
+		// IPv6 leader
+		{"foo.com", nil, "5@[2001:db8::6]:7",
+			[]expectedRR{
+				{"leader.foo.com.", "2001:db8::6", "AAAA"},
+				{"master.foo.com.", "2001:db8::6", "AAAA"},
+				{"master0.foo.com.", "2001:db8::6", "AAAA"},
+				{"_leader._tcp.foo.com.", "0 0 7 leader.foo.com.", "SRV"},
+				{"_leader._udp.foo.com.", "0 0 7 leader.foo.com.", "SRV"},
 			}},
 		// single master: leader and fallback
 		{"foo.com", []string{"6:7"}, "5@6:7",
@@ -50,8 +66,8 @@ func TestMasterRecord(t *testing.T) {
 				{"leader.foo.com.", "6", "A"},
 				{"master.foo.com.", "6", "A"},
 				{"master0.foo.com.", "6", "A"},
-				{"_leader._tcp.foo.com.", "leader.foo.com.:7", "SRV"},
-				{"_leader._udp.foo.com.", "leader.foo.com.:7", "SRV"},
+				{"_leader._tcp.foo.com.", "0 0 7 leader.foo.com.", "SRV"},
+				{"_leader._udp.foo.com.", "0 0 7 leader.foo.com.", "SRV"},
 			}},
 		// leader not in fallback list
 		{"foo.com", []string{"8:9"}, "5@6:7",
@@ -61,8 +77,8 @@ func TestMasterRecord(t *testing.T) {
 				{"master.foo.com.", "8", "A"},
 				{"master1.foo.com.", "6", "A"},
 				{"master0.foo.com.", "8", "A"},
-				{"_leader._tcp.foo.com.", "leader.foo.com.:7", "SRV"},
-				{"_leader._udp.foo.com.", "leader.foo.com.:7", "SRV"},
+				{"_leader._tcp.foo.com.", "0 0 7 leader.foo.com.", "SRV"},
+				{"_leader._udp.foo.com.", "0 0 7 leader.foo.com.", "SRV"},
 			}},
 		// duplicate fallback masters, leader not in fallback list
 		{"foo.com", []string{"8:9", "8:9"}, "5@6:7",
@@ -72,8 +88,8 @@ func TestMasterRecord(t *testing.T) {
 				{"master.foo.com.", "8", "A"},
 				{"master1.foo.com.", "6", "A"},
 				{"master0.foo.com.", "8", "A"},
-				{"_leader._tcp.foo.com.", "leader.foo.com.:7", "SRV"},
-				{"_leader._udp.foo.com.", "leader.foo.com.:7", "SRV"},
+				{"_leader._tcp.foo.com.", "0 0 7 leader.foo.com.", "SRV"},
+				{"_leader._udp.foo.com.", "0 0 7 leader.foo.com.", "SRV"},
 			}},
 		// leader that's also listed in the fallback list (at the end)
 		{"foo.com", []string{"8:9", "6:7"}, "5@6:7",
@@ -83,8 +99,8 @@ func TestMasterRecord(t *testing.T) {
 				{"master.foo.com.", "8", "A"},
 				{"master1.foo.com.", "6", "A"},
 				{"master0.foo.com.", "8", "A"},
-				{"_leader._tcp.foo.com.", "leader.foo.com.:7", "SRV"},
-				{"_leader._udp.foo.com.", "leader.foo.com.:7", "SRV"},
+				{"_leader._tcp.foo.com.", "0 0 7 leader.foo.com.", "SRV"},
+				{"_leader._udp.foo.com.", "0 0 7 leader.foo.com.", "SRV"},
 			}},
 		// duplicate leading masters in the fallback list
 		{"foo.com", []string{"8:9", "6:7", "6:7"}, "5@6:7",
@@ -94,8 +110,8 @@ func TestMasterRecord(t *testing.T) {
 				{"master.foo.com.", "8", "A"},
 				{"master1.foo.com.", "6", "A"},
 				{"master0.foo.com.", "8", "A"},
-				{"_leader._tcp.foo.com.", "leader.foo.com.:7", "SRV"},
-				{"_leader._udp.foo.com.", "leader.foo.com.:7", "SRV"},
+				{"_leader._tcp.foo.com.", "0 0 7 leader.foo.com.", "SRV"},
+				{"_leader._udp.foo.com.", "0 0 7 leader.foo.com.", "SRV"},
 			}},
 		// leader that's also listed in the fallback list (in the middle)
 		{"foo.com", []string{"8:9", "6:7", "bob:0"}, "5@6:7",
@@ -107,42 +123,38 @@ func TestMasterRecord(t *testing.T) {
 				{"master0.foo.com.", "8", "A"},
 				{"master1.foo.com.", "6", "A"},
 				{"master2.foo.com.", "bob", "A"},
-				{"_leader._tcp.foo.com.", "leader.foo.com.:7", "SRV"},
-				{"_leader._udp.foo.com.", "leader.foo.com.:7", "SRV"},
+				{"_leader._tcp.foo.com.", "0 0 7 leader.foo.com.", "SRV"},
+				{"_leader._udp.foo.com.", "0 0 7 leader.foo.com.", "SRV"},
 			}},
 	}
 	for i, tc := range tt {
 		rg := &RecordGenerator{}
-		rg.As = make(rrs)
-		rg.SRVs = make(rrs)
+		rg.resetRecords()
 		t.Logf("test case %d", i+1)
-		rg.masterRecord(tc.domain, tc.masters, tc.leader)
+		rg.masterRecord(DefaultNamingTemplates(), tc.domain, tc.masters, tc.leader)
 		if tc.expect == nil {
 			if len(rg.As) > 0 {
 				t.Fatalf("test case %d: unexpected As: %v", i+1, rg.As)
 			}
+			if len(rg.AAAAs) > 0 {
+				t.Fatalf("test case %d: unexpected AAAAs: %v", i+1, rg.AAAAs)
+			}
 			if len(rg.SRVs) > 0 {
 				t.Fatalf("test case %d: unexpected SRVs: %v", i+1, rg.SRVs)
 			}
 		}
-		expectedA := make(rrs)
-		expectedSRV := make(rrs)
-		for _, e := range tc.expect {
-			found := rg.exists(e.name, e.host, e.rtype)
-			if !found {
-				t.Fatalf("test case %d: missing expected record: name=%q host=%q rtype=%s, As=%v", i+1, e.name, e.host, e.rtype, rg.As)
-			}
-			if e.rtype == "A" {
-				expectedA[e.name] = append(expectedA[e.name], e.host)
-			} else {
-				expectedSRV[e.name] = append(expectedSRV[e.name], e.host)
-			}
+		expected := map[string]map[string][]string{
+			"A":    {},
+			"AAAA": {},
+			"SRV":  {},
 		}
-		if !reflect.DeepEqual(rg.As, expectedA) {
-			t.Fatalf("test case %d: expected As of %v instead of %v", i+1, expectedA, rg.As)
+		for _, e := range tc.expect {
+			expected[e.rtype][e.name] = append(expected[e.rtype][e.name], e.host)
 		}
-		if !reflect.DeepEqual(rg.SRVs, expectedSRV) {
-			t.Fatalf("test case %d: expected SRVs of %v instead of %v", i+1, expectedSRV, rg.SRVs)
+		for _, rtype := range []string{"A", "AAAA", "SRV"} {
+			if got, want := values(rg.store(rtype)), expected[rtype]; !reflect.DeepEqual(got, want) {
+				t.Fatalf("test case %d: expected %s records of %v instead of %v", i+1, rtype, want, got)
+			}
 		}
 	}
 }
@@ -155,9 +167,82 @@ func TestLeaderIP(t *testing.T) {
 	if ip != "144.76.157.37" {
 		t.Error("not parsing ip")
 	}
+
+	if ip := leaderIP("master@[2001:db8::1]:5050"); ip != "2001:db8::1" {
+		t.Errorf("not parsing ipv6, got %q", ip)
+	}
+}
+
+func TestFindMasterOperatorAPI(t *testing.T) {
+	var leaderAddr string
+	leader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var call struct{ Type string }
+		if r.Method != "POST" || r.URL.Path != "/api/v1" || json.NewDecoder(r.Body).Decode(&call) != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		switch call.Type {
+		case "GET_MASTER":
+			_, _ = fmt.Fprintf(w, `{"type": "GET_MASTER", "get_master": {"master_info": {"pid": "master@%s"}}}`, leaderAddr)
+		case "GET_STATE":
+			_, _ = fmt.Fprint(w, `{"type": "GET_STATE", "get_state": {
+				"get_tasks": {"tasks": [{"name": "web", "task_id": {"value": "web.1"}, "framework_id": {"value": "F1"}, "agent_id": {"value": "S1"}, "state": "TASK_RUNNING"}]},
+				"get_frameworks": {"frameworks": [{"framework_info": {"id": {"value": "F1"}, "name": "marathon"}}]},
+				"get_agents": {"agents": [{"agent_info": {"id": {"value": "S1"}, "hostname": "10.0.0.11", "port": 5051}, "pid": "slave(1)@10.0.0.11:5051"}]}
+			}}`)
+		default:
+			http.Error(w, "unsupported call", http.StatusBadRequest)
+		}
+	}))
+	defer leader.Close()
+	leaderAddr = strings.TrimPrefix(leader.URL, "http://")
+
+	// non-leading masters redirect operator API calls to the leader
+	follower := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, leader.URL+r.URL.Path, http.StatusTemporaryRedirect)
+	}))
+	defer follower.Close()
+
+	// masters predating the operator API only serve state.json
+	var legacyAddr string
+	legacy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/master/state.json" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"leader": "master@%s", "frameworks": [{"id": "F2", "name": "chronos"}]}`, legacyAddr)
+	}))
+	defer legacy.Close()
+	legacyAddr = strings.TrimPrefix(legacy.URL, "http://")
+
+	var rg RecordGenerator
+	c := NewConfig()
+	c.StateSource = "operator"
+	for i, tt := range []struct {
+		master    string
+		leader    string
+		framework string
+	}{
+		{leaderAddr, leaderAddr, "marathon"},
+		{strings.TrimPrefix(follower.URL, "http://"), leaderAddr, "marathon"},
+		{legacyAddr, legacyAddr, "chronos"},
+	} {
+		sj, err := rg.findMaster(c, "", tt.master)
+		if err != nil {
+			t.Errorf("test #%d: unexpected error: %v", i, err)
+			continue
+		}
+		if want := "master@" + tt.leader; sj.Leader != want {
+			t.Errorf("test #%d: got leader %q, want %q", i, sj.Leader, want)
+		}
+		if len(sj.Frameworks) != 1 || sj.Frameworks[0].Name != tt.framework {
+			t.Errorf("test #%d: got frameworks %+v, want %q", i, sj.Frameworks, tt.framework)
+		}
+	}
 }
 
-func testRecordGenerator(t *testing.T, spec labels.Func, ipSources []string) RecordGenerator {
+// fakeState returns the state of the fake.json factory.
+func fakeState(t *testing.T) state.State {
 	var sj state.State
 
 	b, err := ioutil.ReadFile("../factories/fake.json")
@@ -166,28 +251,82 @@ func testRecordGenerator(t *testing.T, spec labels.Func, ipSources []string) Rec
 	} else if err = json.Unmarshal(b, &sj); err != nil {
 		t.Fatal(err)
 	}
+	return sj
+}
 
+func testRecordGenerator(t *testing.T, ipSources []string, opts ...func(*Config)) RecordGenerator {
+	sj := fakeState(t)
 	sj.Leader = "master@144.76.157.37:5050"
 	masters := []string{"144.76.157.37:5050"}
 	staticEntries := []StaticEntry{
 		StaticEntry{Fqdn: "hello.static.mesos.", Type: "A", Value: "120.0.0.1"},
 		StaticEntry{Fqdn: "_static._tcp.mesos.", Type: "SRV", Value: "120.0.0.1:434"},
+		StaticEntry{Fqdn: "hello.static.mesos.", Type: "AAAA", Value: "fd00::1"},
+		StaticEntry{Fqdn: "www.static.mesos.", Type: "CNAME", Value: "hello.static.mesos."},
+		StaticEntry{Fqdn: "static.mesos.", Type: "TXT", Value: "owner=infra"},
+		StaticEntry{Fqdn: "static.mesos.", Type: "MX", Value: "10 mail.static.mesos."},
+		StaticEntry{Fqdn: "120.0.0.1", Type: "PTR", Value: "hello.static.mesos."},
+		StaticEntry{Fqdn: "Sub.Static.Mesos.", Type: "NS", Value: "ns1.sub.static.mesos."},
+		StaticEntry{Fqdn: "ttl.static.mesos.", Type: "A", Value: "120.0.0.2", TTL: 300},
+		StaticEntry{Fqdn: "*.apps.mesos.", Type: "A", Value: "120.0.0.3"},
+		StaticEntry{Fqdn: "www.apps.mesos.", Type: "TXT", Value: "owner=web"},
+		StaticEntry{Fqdn: "x.y.apps.mesos.", Type: "TXT", Value: "owner=x"},
+	}
+
+	c := NewConfig()
+	c.SOARname = "mesos-dns.mesos."
+	c.Listener = "127.0.0.1"
+	c.EnforceRFC952 = true
+	c.IPSources = ipSources
+	c.StaticEntryConfig.Entries = staticEntries
+	for _, opt := range opts {
+		opt(&c)
 	}
 
 	var rg RecordGenerator
-	if err := rg.InsertState(sj, "mesos", "mesos-dns.mesos.", "127.0.0.1", masters, ipSources, staticEntries, spec); err != nil {
+	if err := rg.InsertState(sj, c, masters); err != nil {
 		t.Fatal(err)
 	}
 
 	return rg
 }
 
+// ensure CNAMEs are the only records of their names
+func TestConflictingCNAMEs(t *testing.T) {
+	rg := testRecordGenerator(t, []string{"docker", "mesos", "host"}, func(c *Config) {
+		c.StaticEntryConfig.Entries = append(c.StaticEntryConfig.Entries,
+			StaticEntry{Fqdn: "hello.static.mesos.", Type: "CNAME", Value: "www.static.mesos."},
+			StaticEntry{Fqdn: "nginx.marathon.mesos.", Type: "CNAME", Value: "hello.static.mesos."},
+			StaticEntry{Fqdn: "two.static.mesos.", Type: "CNAME", Value: "hello.static.mesos."},
+			StaticEntry{Fqdn: "two.static.mesos.", Type: "CNAME", Value: "ttl.static.mesos."},
+		)
+	})
+
+	for i, tt := range []struct {
+		name string
+		want []string
+	}{
+		{"www.static.mesos.", []string{"hello.static.mesos."}},
+		{"hello.static.mesos.", nil},   // static A and AAAA records
+		{"nginx.marathon.mesos.", nil}, // task records
+		{"two.static.mesos.", []string{"hello.static.mesos."}},
+	} {
+		if got := targets(rg.CNAMEs[tt.name]); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: %q: got: %q, want: %q", i, tt.name, got, tt.want)
+		}
+	}
+	if len(rg.As["hello.static.mesos."]) == 0 || len(rg.As["nginx.marathon.mesos."]) == 0 {
+		t.Error("records of names with conflicting CNAMEs were dropped")
+	}
+}
+
 // ensure we are parsing what we think we are
 func TestInsertState(t *testing.T) {
-	rg := testRecordGenerator(t, labels.RFC952, []string{"docker", "mesos", "host"})
-	rgDocker := testRecordGenerator(t, labels.RFC952, []string{"docker", "host"})
-	rgMesos := testRecordGenerator(t, labels.RFC952, []string{"mesos", "host"})
-	rgSlave := testRecordGenerator(t, labels.RFC952, []string{"host"})
+	rg := testRecordGenerator(t, []string{"docker", "mesos", "host"})
+	rgDocker := testRecordGenerator(t, []string{"docker", "host"})
+	rgMesos := testRecordGenerator(t, []string{"mesos", "host"})
+	rgSlave := testRecordGenerator(t, []string{"host"})
+	rgNetinfo := testRecordGenerator(t, []string{"netinfo", "host"})
 
 	for i, tt := range []struct {
 		rrs  rrs
@@ -208,26 +347,45 @@ func TestInsertState(t *testing.T) {
 		{rg.As, "marathon.mesos.", []string{"1.2.3.11"}},
 		{rg.As, "hello.static.mesos.", []string{"120.0.0.1"}},
 		{rg.SRVs, "_poseidon._tcp.marathon.mesos.", nil},
-		{rg.SRVs, "_leader._tcp.mesos.", []string{"leader.mesos.:5050"}},
+		{rg.SRVs, "_leader._tcp.mesos.", []string{"0 0 5050 leader.mesos."}},
 		{rg.SRVs, "_liquor-store._tcp.marathon.mesos.", []string{
-			"liquor-store-17700-0.marathon.mesos.:80",
-			"liquor-store-17700-0.marathon.mesos.:443",
-			"liquor-store-7581-1.marathon.mesos.:80",
-			"liquor-store-7581-1.marathon.mesos.:443",
+			"0 0 80 liquor-store-rn76murd-0.marathon.mesos.",
+			"0 0 443 liquor-store-rn76murd-0.marathon.mesos.",
+			"0 0 80 liquor-store-ahdjzdbz-1.marathon.mesos.",
+			"0 0 443 liquor-store-ahdjzdbz-1.marathon.mesos.",
+		}},
+		{rg.SRVs, "_http._liquor-store._tcp.marathon.mesos.", []string{
+			"0 0 80 liquor-store-rn76murd-0.marathon.mesos.",
+			"0 0 80 liquor-store-ahdjzdbz-1.marathon.mesos.",
 		}},
+		{rg.SRVs, "_https._liquor-store._tcp.marathon.mesos.", []string{
+			"0 0 443 liquor-store-rn76murd-0.marathon.mesos.",
+			"0 0 443 liquor-store-ahdjzdbz-1.marathon.mesos.",
+		}},
+		{rg.SRVs, "_http._liquor-store._udp.marathon.mesos.", nil},
 		{rg.SRVs, "_liquor-store._udp.marathon.mesos.", nil},
 		{rg.SRVs, "_liquor-store.marathon.mesos.", nil},
 		{rg.SRVs, "_car-store._tcp.marathon.mesos.", []string{
-			"car-store-50548-0.marathon.slave.mesos.:31364",
-			"car-store-50548-0.marathon.slave.mesos.:31365",
+			"0 0 31364 car-store-qrsmip4e-0.marathon.slave.mesos.",
+			"0 0 31365 car-store-qrsmip4e-0.marathon.slave.mesos.",
 		}},
 		{rg.SRVs, "_car-store._udp.marathon.mesos.", []string{
-			"car-store-50548-0.marathon.slave.mesos.:31364",
-			"car-store-50548-0.marathon.slave.mesos.:31365",
+			"0 0 31364 car-store-qrsmip4e-0.marathon.slave.mesos.",
+			"0 0 31365 car-store-qrsmip4e-0.marathon.slave.mesos.",
+		}},
+		{rg.SRVs, "_slave._tcp.mesos.", []string{"0 0 5051 slave.mesos."}},
+		{rg.As, "20140803-125133-3041283216-5050-2410-0.slave.mesos.", []string{"1.2.3.11"}},
+		{rg.SRVs, "_slave._tcp.20140803-125133-3041283216-5050-2410-0.slave.mesos.", []string{
+			"0 0 5051 20140803-125133-3041283216-5050-2410-0.slave.mesos.",
 		}},
-		{rg.SRVs, "_slave._tcp.mesos.", []string{"slave.mesos.:5051"}},
-		{rg.SRVs, "_framework._tcp.marathon.mesos.", []string{"marathon.mesos.:25501"}},
-		{rg.SRVs, "_static._tcp.mesos.", []string{"120.0.0.1:434"}},
+		{rg.SRVs, "_framework._tcp.marathon.mesos.", []string{"0 0 25501 marathon.mesos."}},
+		{rg.SRVs, "_static._tcp.mesos.", []string{"0 0 434 120.0.0.1"}},
+		{rg.AAAAs, "hello.static.mesos.", []string{"fd00::1"}},
+		{rg.CNAMEs, "www.static.mesos.", []string{"hello.static.mesos."}},
+		{rg.TXTs, "static.mesos.", []string{"owner=infra"}},
+		{rg.MXs, "static.mesos.", []string{"10 mail.static.mesos."}},
+		{rg.PTRs, "1.0.0.120.in-addr.arpa.", []string{"hello.static.mesos."}},
+		{rg.NSs, "sub.static.mesos.", []string{"ns1.sub.static.mesos."}},
 
 		{rgSlave.As, "liquor-store.marathon.mesos.", []string{"1.2.3.11", "1.2.3.12"}},
 		{rgSlave.As, "liquor-store.marathon.slave.mesos.", []string{"1.2.3.11", "1.2.3.12"}},
@@ -243,8 +401,422 @@ func TestInsertState(t *testing.T) {
 		{rgDocker.As, "liquor-store.marathon.slave.mesos.", []string{"1.2.3.11", "1.2.3.12"}},
 		{rgDocker.As, "nginx.marathon.mesos.", []string{"1.2.3.11"}},
 		{rgDocker.As, "car-store.marathon.slave.mesos.", []string{"1.2.3.11"}},
+
+		{rgNetinfo.As, "dual-stack.marathon.mesos.", []string{"10.3.0.5"}},
+		{rgNetinfo.AAAAs, "dual-stack.marathon.mesos.", []string{"fd01:b::1:8000:2"}},
+		{rgNetinfo.As, "dual-stack.marathon.slave.mesos.", []string{"1.2.3.11"}},
+		{rgNetinfo.AAAAs, "dual-stack.marathon.slave.mesos.", nil},
+		{rgNetinfo.As, "nginx.marathon.mesos.", []string{"1.2.3.11"}},
+		{rgSlave.As, "dual-stack.marathon.mesos.", []string{"1.2.3.11"}},
+		{rgSlave.AAAAs, "dual-stack.marathon.mesos.", nil},
+
+		{rg.PTRs, "1.0.3.10.in-addr.arpa.", []string{"liquor-store-rn76murd-0.marathon.mesos."}},
+		{rg.PTRs, "3.0.3.10.in-addr.arpa.", []string{"nginx-zhrv3pmt-0.marathon.mesos."}},
+		{rg.PTRs, "11.3.2.1.in-addr.arpa.", []string{"20140803-125133-3041283216-5050-2410-0.slave.mesos."}},
+		{rg.PTRs, "37.157.76.144.in-addr.arpa.", []string{"master0.mesos."}},
+		{rgSlave.PTRs, "11.3.2.1.in-addr.arpa.", []string{"20140803-125133-3041283216-5050-2410-0.slave.mesos."}},
+		{rgSlave.PTRs, "1.0.3.10.in-addr.arpa.", nil},
+		{rgNetinfo.PTRs, "2.0.0.0.0.0.0.8.1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.b.0.0.0.1.0.d.f.ip6.arpa.", []string{"dual-stack-ubbmrigu-0.marathon.mesos."}},
+	} {
+		if got := targets(tt.rrs[tt.name]); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: %q: got: %q, want: %q", i, tt.name, got, tt.want)
+		}
+	}
+}
+
+// ensure we honor the health check mode when publishing tasks
+func TestTaskHealth(t *testing.T) {
+	rgIgnore := testRecordGenerator(t, []string{"docker", "mesos", "host"})
+	rgHealthy := testRecordGenerator(t, []string{"docker", "mesos", "host"}, func(c *Config) {
+		c.HealthCheckMode = "healthy-only"
+	})
+
+	for i, tt := range []struct {
+		rrs  rrs
+		name string
+		want []string
+	}{
+		{rgIgnore.As, "liquor-store.marathon.mesos.", []string{"10.3.0.1", "10.3.0.2"}},
+		{rgHealthy.As, "liquor-store.marathon.mesos.", []string{"10.3.0.1"}},
+		{rgHealthy.As, "liquor-store-ahdjzdbz-1.marathon.mesos.", nil},
+		{rgHealthy.SRVs, "_http._liquor-store._tcp.marathon.mesos.", []string{
+			"0 0 80 liquor-store-rn76murd-0.marathon.mesos.",
+		}},
+		{rgHealthy.As, "nginx.marathon.mesos.", []string{"10.3.0.3"}},
+	} {
+		if got := targets(tt.rrs[tt.name]); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: %q: got: %q, want: %q", i, tt.name, got, tt.want)
+		}
+	}
+
+	if rr := rgIgnore.As["liquor-store-ahdjzdbz-1.marathon.mesos."]; len(rr) != 1 || !rr[0].Unhealthy {
+		t.Errorf("expected unhealthy record, got %+v", rr)
+	}
+	if rr := rgIgnore.As["liquor-store-rn76murd-0.marathon.mesos."]; len(rr) != 1 || rr[0].Unhealthy {
+		t.Errorf("expected healthy record, got %+v", rr)
+	}
+}
+
+// ensure records of tasks holding the same data answer for all of them
+func TestDuplicateTaskRecords(t *testing.T) {
+	var sj state.State
+	if err := json.Unmarshal([]byte(`{
+		"leader": "master@10.0.0.1:5050",
+		"frameworks": [{"id": "F1", "name": "marathon", "hostname": "10.0.0.1", "tasks": [
+			{"id": "web.1", "name": "web", "slave_id": "S1", "state": "TASK_RUNNING",
+				"statuses": [{"state": "TASK_RUNNING", "healthy": false}]},
+			{"id": "web.2", "name": "web", "slave_id": "S1", "state": "TASK_RUNNING",
+				"statuses": [{"state": "TASK_RUNNING", "healthy": true}]},
+			{"id": "api.1", "name": "api", "slave_id": "S1", "state": "TASK_RUNNING",
+				"discovery": {"visibility": "CLUSTER"}},
+			{"id": "api.2", "name": "api", "slave_id": "S1", "state": "TASK_RUNNING",
+				"discovery": {"visibility": "EXTERNAL"}}
+		]}],
+		"slaves": [{"id": "S1", "hostname": "10.0.0.11", "pid": "slave(1)@10.0.0.11:5051"}]
+	}`), &sj); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewConfig()
+	c.IPSources = []string{"host"}
+	var rg RecordGenerator
+	if err := rg.InsertState(sj, c, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	rrs := rg.As["web.marathon.mesos."]
+	if len(rrs) != 1 || rrs[0].Target != "10.0.0.11" {
+		t.Fatalf("got %+v, want a single record of 10.0.0.11", rrs)
+	}
+	if rrs[0].Unhealthy {
+		t.Errorf("got unhealthy record of healthy task: %+v", rrs[0])
+	}
+
+	// the record of the EXTERNAL task is visible to external clients even
+	// though the CLUSTER task came first
+	if rrs := rg.External().As["api.marathon.mesos."]; len(rrs) != 1 || rrs[0].Visibility != "EXTERNAL" {
+		t.Errorf("got external records %+v, want a single EXTERNAL record", rrs)
+	}
+}
+
+func TestTaskStates(t *testing.T) {
+	defer func(now func() time.Time) { timeNow = now }(timeNow)
+	timeNow = func() time.Time { return time.Unix(1500000100, 0) }
+
+	// nginx is staging, chronos is being killed and car-store's agent became
+	// unreachable 100 seconds ago
+	sj := fakeState(t)
+	for i, f := range sj.Frameworks {
+		if f.Name != "marathon" {
+			continue
+		}
+		var tasks []state.Task
+		for _, task := range f.Tasks {
+			switch task.Name {
+			case "nginx":
+				task.State = "TASK_STAGING"
+			case "chronos":
+				task.State = "TASK_KILLING"
+			case "car-store":
+				task.State = "TASK_UNREACHABLE"
+				task.Statuses = append(task.Statuses, state.Status{
+					State:     "TASK_UNREACHABLE",
+					Timestamp: 1500000000,
+				})
+				sj.Frameworks[i].UnreachableTasks = append(sj.Frameworks[i].UnreachableTasks, task)
+				continue
+			}
+			tasks = append(tasks, task)
+		}
+		sj.Frameworks[i].Tasks = tasks
+	}
+
+	for i, tt := range []struct {
+		states      []string
+		gracePeriod int
+		want        []string
+	}{
+		{[]string{"TASK_RUNNING"}, 0, []string{"liquor-store"}},
+		{[]string{"TASK_STAGING", "TASK_RUNNING"}, 0, []string{"liquor-store", "nginx"}},
+		{[]string{"TASK_RUNNING", "TASK_KILLING"}, 0, []string{"chronos", "liquor-store"}},
+		{[]string{"TASK_RUNNING"}, 60, []string{"liquor-store"}},
+		{[]string{"TASK_RUNNING"}, 120, []string{"car-store", "liquor-store"}},
+		{[]string{"TASK_RUNNING", "TASK_UNREACHABLE"}, 0, []string{"car-store", "liquor-store"}},
+	} {
+		c := NewConfig()
+		c.IPSources = []string{"docker", "mesos", "host"}
+		c.TaskStates = tt.states
+		c.UnreachableGracePeriod = tt.gracePeriod
+
+		var rg RecordGenerator
+		if err := rg.InsertState(sj, c, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, name := range []string{"car-store", "chronos", "liquor-store", "nginx"} {
+			if len(rg.As[name+".marathon.mesos."]) > 0 {
+				got = append(got, name)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: got %q, want %q", i, got, tt.want)
+		}
+	}
+}
+
+func TestSRVPriorityWeight(t *testing.T) {
+	sj := fakeState(t)
+	for _, f := range sj.Frameworks {
+		for i := range f.Tasks {
+			task := &f.Tasks[i]
+			switch task.ID {
+			case "liquor-store.b8db9f73-562f-11e4-a088-c20493233aa5":
+				task.Labels = append(task.Labels,
+					state.Label{Key: "MESOS_DNS_SRV_PRIORITY", Value: "1"},
+					state.Label{Key: "MESOS_DNS_SRV_WEIGHT", Value: "90"})
+			case "liquor-store.b71166c1-562f-11e4-a088-c20493233aa5":
+				task.Labels = append(task.Labels, state.Label{Key: "MESOS_DNS_SRV_WEIGHT", Value: "10"})
+			case "car-store.43758382-562f-11e4-a088-c20493233aa5":
+				task.Labels = append(task.Labels, state.Label{Key: "MESOS_DNS_SRV_WEIGHT", Value: "heavy"})
+			}
+		}
+	}
+
+	c := NewConfig()
+	c.IPSources = []string{"docker", "mesos", "host"}
+	var rg RecordGenerator
+	if err := rg.InsertState(sj, c, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tt := range []struct {
+		rrs  rrs
+		name string
+		want []string
+	}{
+		{rg.SRVs, "_http._liquor-store._tcp.marathon.mesos.", []string{
+			"1 90 80 liquor-store-rn76murd-0.marathon.mesos.",
+			"0 10 80 liquor-store-ahdjzdbz-1.marathon.mesos.",
+		}},
+		{rg.SRVs, "_car-store._tcp.marathon.mesos.", []string{
+			"0 0 31364 car-store-qrsmip4e-0.marathon.slave.mesos.",
+			"0 0 31365 car-store-qrsmip4e-0.marathon.slave.mesos.",
+		}},
+		{rg.As, "liquor-store.marathon.mesos.", []string{"10.3.0.1", "10.3.0.2"}},
 	} {
-		if got := tt.rrs[tt.name]; !reflect.DeepEqual(got, tt.want) {
+		if got := targets(tt.rrs[tt.name]); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: %q: got: %q, want: %q", i, tt.name, got, tt.want)
+		}
+	}
+	if rr := rg.As["liquor-store.marathon.mesos."]; len(rr) == 0 || rr[0].Priority != 0 || rr[0].Weight != 0 {
+		t.Errorf("expected A records without priority and weight, got %+v", rr)
+	}
+}
+
+func TestGroupRecords(t *testing.T) {
+	sj := fakeState(t)
+	for _, f := range sj.Frameworks {
+		for i := range f.Tasks {
+			appID := map[string]string{
+				"liquor-store.b8db9f73-562f-11e4-a088-c20493233aa5": "/payments/api/v2",
+				"car-store.43758382-562f-11e4-a088-c20493233aa5":    "/payments/Web",
+				"nginx.1bc32344-3dda-11e4-a088-c20493233aa5":        "/nginx",
+			}[f.Tasks[i].ID]
+			if appID != "" {
+				f.Tasks[i].Labels = append(f.Tasks[i].Labels, state.Label{Key: "MARATHON_APP_ID", Value: appID})
+			}
+		}
+	}
+
+	c := NewConfig()
+	c.IPSources = []string{"docker", "mesos", "host"}
+	c.EnforceRFC952 = true
+	c.AppIDLabel = "MARATHON_APP_ID"
+	var rg RecordGenerator
+	if err := rg.InsertState(sj, c, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tt := range []struct {
+		rrs  rrs
+		name string
+		want []string
+	}{
+		{rg.As, "v2.api.payments.marathon.mesos.", []string{"10.3.0.1"}},
+		{rg.As, "web.payments.marathon.mesos.", []string{"1.2.3.11"}},
+		{rg.As, "api.payments.marathon.mesos.", nil},
+		{rg.SRVs, "_v2._tcp.api.payments.marathon.mesos.", []string{
+			"0 0 80 liquor-store-rn76murd-0.marathon.mesos.",
+			"0 0 443 liquor-store-rn76murd-0.marathon.mesos.",
+		}},
+		{rg.SRVs, "_api._tcp.payments.marathon.mesos.", []string{
+			"0 0 80 liquor-store-rn76murd-0.marathon.mesos.",
+			"0 0 443 liquor-store-rn76murd-0.marathon.mesos.",
+		}},
+		{rg.SRVs, "_payments._tcp.marathon.mesos.", []string{
+			"0 0 80 liquor-store-rn76murd-0.marathon.mesos.",
+			"0 0 443 liquor-store-rn76murd-0.marathon.mesos.",
+			"0 0 31364 car-store-qrsmip4e-0.marathon.slave.mesos.",
+			"0 0 31365 car-store-qrsmip4e-0.marathon.slave.mesos.",
+		}},
+		{rg.SRVs, "_web._udp.payments.marathon.mesos.", []string{
+			"0 0 31364 car-store-qrsmip4e-0.marathon.slave.mesos.",
+			"0 0 31365 car-store-qrsmip4e-0.marathon.slave.mesos.",
+		}},
+		{rg.SRVs, "_nginx._tcp.marathon.mesos.", nil},
+	} {
+		if got := targets(tt.rrs[tt.name]); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: %q: got: %q, want: %q", i, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	rg := testRecordGenerator(t, []string{"docker", "mesos", "host"}, func(c *Config) {
+		c.TaskWildcards = true
+	})
+
+	for i, tt := range []struct {
+		name, want string
+	}{
+		{"hello.static.mesos.", "hello.static.mesos."},
+		{"web.apps.mesos.", "*.apps.mesos."},
+		{"a.b.apps.mesos.", "*.apps.mesos."},
+		{"*.apps.mesos.", "*.apps.mesos."},
+		{"www.apps.mesos.", "www.apps.mesos."},
+		{"sub.www.apps.mesos.", "sub.www.apps.mesos."},
+		{"apps.mesos.", "apps.mesos."},
+		// y.apps.mesos. is an empty non-terminal
+		{"y.apps.mesos.", "y.apps.mesos."},
+		{"z.y.apps.mesos.", "z.y.apps.mesos."},
+		{"x.y.apps.mesos.", "x.y.apps.mesos."},
+		{"missing.mesos.", "missing.mesos."},
+		{"web.nginx.marathon.mesos.", "*.nginx.marathon.mesos."},
+		{"nginx.marathon.mesos.", "nginx.marathon.mesos."},
+	} {
+		if got := rg.Match(tt.name); got != tt.want {
+			t.Errorf("test #%d: %q: got: %q, want: %q", i, tt.name, got, tt.want)
+		}
+	}
+
+	if got, want := targets(rg.As["*.nginx.marathon.mesos."]), []string{"10.3.0.3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+	if rg := testRecordGenerator(t, []string{"docker", "mesos", "host"}); rg.Contains("*.nginx.marathon.mesos.") {
+		t.Error("unexpected task wildcard records")
+	}
+}
+
+func TestVisibility(t *testing.T) {
+	rg := testRecordGenerator(t, []string{"docker", "mesos", "host"})
+	ext := rg.External()
+
+	for i, tt := range []struct {
+		rrs  rrs
+		name string
+		want []string
+	}{
+		{rg.As, "liquor-store.marathon.mesos.", []string{"10.3.0.1", "10.3.0.2"}},
+		{ext.As, "liquor-store.marathon.mesos.", nil},
+		{ext.SRVs, "_http._liquor-store._tcp.marathon.mesos.", nil},
+		{ext.PTRs, "1.0.3.10.in-addr.arpa.", nil},
+		{ext.As, "nginx.marathon.mesos.", []string{"10.3.0.3"}},
+		{ext.As, "leader.mesos.", []string{"144.76.157.37"}},
+		{ext.As, "hello.static.mesos.", []string{"120.0.0.1"}},
+	} {
+		if got := targets(tt.rrs[tt.name]); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: %q: got: %q, want: %q", i, tt.name, got, tt.want)
+		}
+	}
+
+	// tasks with FRAMEWORK visibility aren't published at all
+	sj := fakeState(t)
+	for _, f := range sj.Frameworks {
+		for i := range f.Tasks {
+			f.Tasks[i].DiscoveryInfo.Visibilty = "FRAMEWORK"
+		}
+	}
+	c := NewConfig()
+	c.IPSources = []string{"docker", "mesos", "host"}
+	var rgFramework RecordGenerator
+	if err := rgFramework.InsertState(sj, c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := rgFramework.As["liquor-store.marathon.mesos."]; len(got) != 0 {
+		t.Errorf("expected no records of tasks with FRAMEWORK visibility, got %+v", got)
+	}
+}
+
+// ensure records carry the TTLs of their frameworks, tasks and static entries
+func TestRecordTTL(t *testing.T) {
+	rg := testRecordGenerator(t, []string{"docker", "mesos", "host"})
+	rgFramework := testRecordGenerator(t, []string{"docker", "mesos", "host"}, func(c *Config) {
+		c.FrameworkTTLs = map[string]int32{"marathon": 30}
+	})
+
+	for i, tt := range []struct {
+		rrs  rrs
+		name string
+		want uint32
+	}{
+		{rg.As, "liquor-store.marathon.mesos.", 0},
+		{rg.As, "nginx.marathon.mesos.", 5},
+		{rg.As, "hello.static.mesos.", 0},
+		{rg.As, "ttl.static.mesos.", 300},
+		{rgFramework.As, "marathon.mesos.", 30},
+		{rgFramework.As, "liquor-store.marathon.mesos.", 30},
+		{rgFramework.SRVs, "_liquor-store._tcp.marathon.mesos.", 30},
+		{rgFramework.As, "nginx.marathon.mesos.", 5},
+		{rgFramework.As, "some-box.chronoswithaspaceandmixe.mesos.", 0},
+	} {
+		rrs := tt.rrs[tt.name]
+		if len(rrs) == 0 {
+			t.Errorf("test #%d: %q: no records", i, tt.name)
+		}
+		for _, rr := range rrs {
+			if rr.TTL != tt.want {
+				t.Errorf("test #%d: %q: got TTL %d, want %d", i, tt.name, rr.TTL, tt.want)
+			}
+		}
+	}
+}
+
+// ensure we publish the metadata of tasks in TXT records
+func TestTaskTXT(t *testing.T) {
+	rg := testRecordGenerator(t, []string{"docker", "mesos", "host"})
+	rgLabels := testRecordGenerator(t, []string{"docker", "mesos", "host"}, func(c *Config) {
+		c.TXTLabels = []string{"team", "canary", "foo"}
+	})
+
+	for i, tt := range []struct {
+		rrs  rrs
+		name string
+		want []string
+	}{
+		{rg.TXTs, "liquor-store.marathon.mesos.", []string{"version=1.0", "environment=prod", "location=eu-west"}},
+		{rg.TXTs, "liquor-store-rn76murd-0.marathon.mesos.", []string{"version=1.0", "environment=prod", "location=eu-west"}},
+		{rg.TXTs, "nginx.marathon.mesos.", nil},
+		{rgLabels.TXTs, "liquor-store.marathon.mesos.", []string{
+			"version=1.0",
+			"environment=prod",
+			"location=eu-west",
+			"team=spirits",
+			"canary=Teneriffa",
+			"foo=bar",
+			"canary=Lanzarote",
+		}},
+		{rgLabels.TXTs, "liquor-store-rn76murd-0.marathon.mesos.", []string{
+			"version=1.0",
+			"environment=prod",
+			"location=eu-west",
+			"team=spirits",
+			"canary=Teneriffa",
+			"foo=bar",
+		}},
+		{rgLabels.TXTs, "liquor-store.marathon.slave.mesos.", nil},
+	} {
+		if got := targets(tt.rrs[tt.name]); !reflect.DeepEqual(got, tt.want) {
 			t.Errorf("test #%d: %q: got: %q, want: %q", i, tt.name, got, tt.want)
 		}
 	}
@@ -255,9 +827,9 @@ func TestNTasks(t *testing.T) {
 	rg := &RecordGenerator{}
 	rg.As = make(rrs)
 
-	rg.insertRR("blah.mesos", "10.0.0.1", "A")
-	rg.insertRR("blah.mesos", "10.0.0.1", "A")
-	rg.insertRR("blah.mesos", "10.0.0.2", "A")
+	rg.insertIP("blah.mesos", "10.0.0.1", Record{})
+	rg.insertIP("blah.mesos", "10.0.0.1", Record{TaskID: "foo"})
+	rg.insertIP("blah.mesos", "10.0.0.2", Record{})
 
 	k, _ := rg.As["blah.mesos"]
 
@@ -266,10 +838,406 @@ func TestNTasks(t *testing.T) {
 	}
 }
 
+// ensure records of unsupported types are dropped
+func TestInsertUnsupportedRR(t *testing.T) {
+	rg := &RecordGenerator{}
+	rg.resetRecords()
+
+	if rg.insertRR("blah.mesos.", Record{Type: "SPF", Target: "v=spf1 -all"}) {
+		t.Error("unexpectedly inserted SPF record")
+	}
+	if rg.Contains("blah.mesos.") {
+		t.Error("unexpected records for blah.mesos.")
+	}
+}
+
+// ensure records carry the identity of the object they originate from
+func TestRecordOrigin(t *testing.T) {
+	rg := testRecordGenerator(t, []string{"docker", "mesos", "host"})
+
+	for i, tt := range []struct {
+		rrs  rrs
+		name string
+		want Record
+	}{
+		{rg.As, "nginx.marathon.mesos.", Record{
+			Type:        "A",
+			Target:      "10.3.0.3",
+			TTL:         5,
+			Class:       TaskClass,
+			TaskID:      "nginx.1bc32344-3dda-11e4-a088-c20493233aa5",
+			FrameworkID: "20140703-014514-3041283216-5050-5348-0000",
+			SlaveID:     "20140803-125133-3041283216-5050-2410-0",
+		}},
+		{rg.SRVs, "_framework._tcp.marathon.mesos.", Record{
+			Type:        "SRV",
+			Target:      "marathon.mesos.",
+			Port:        25501,
+			Class:       FrameworkClass,
+			FrameworkID: "20140703-014514-3041283216-5050-5348-0000",
+		}},
+		{rg.SRVs, "_slave._tcp.mesos.", Record{
+			Type:    "SRV",
+			Target:  "slave.mesos.",
+			Port:    5051,
+			Class:   SlaveClass,
+			SlaveID: "20140916-194712-631065744-5050-4798-2",
+		}},
+		{rg.SRVs, "_https._liquor-store._tcp.marathon.mesos.", Record{
+			Type:        "SRV",
+			Target:      "liquor-store-rn76murd-0.marathon.mesos.",
+			Port:        443,
+			PortName:    "https",
+			Visibility:  "CLUSTER",
+			Class:       TaskClass,
+			TaskID:      "liquor-store.b8db9f73-562f-11e4-a088-c20493233aa5",
+			FrameworkID: "20140703-014514-3041283216-5050-5348-0000",
+			SlaveID:     "20140803-125133-3041283216-5050-2410-0",
+		}},
+		{rg.As, "leader.mesos.", Record{Type: "A", Target: "144.76.157.37", Class: MasterClass}},
+		{rg.MXs, "static.mesos.", Record{Type: "MX", Target: "mail.static.mesos.", Priority: 10, Class: StaticClass}},
+	} {
+		if got := tt.rrs[tt.name]; len(got) == 0 || !reflect.DeepEqual(got[0], tt.want) {
+			t.Errorf("test #%d: %q: got: %+v, want: %+v", i, tt.name, got, tt.want)
+		}
+	}
+}
+
 func TestHashString(t *testing.T) {
-	t.Skip("TODO: Increase entropy, fix the bug!")
-	fn := func(a, b string) bool { return hashString(a) != hashString(b) }
-	if err := quick.Check(fn, &quick.Config{MaxCount: 1e9}); err != nil {
+	fn := func(a, b string) bool {
+		return a == b || hashString(a, maxHashLength) != hashString(b, maxHashLength)
+	}
+	if err := quick.Check(fn, &quick.Config{MaxCount: 1e5}); err != nil {
+		t.Fatal(err)
+	}
+
+	for n := 1; n <= maxHashLength+1; n++ {
+		want := n
+		if want > maxHashLength {
+			want = maxHashLength
+		}
+		h := hashString("liquor-store.b8db9f73-562f-11e4-a088-c20493233aa5", n)
+		if len(h) != want {
+			t.Errorf("hashString(_, %d): got length %d, want %d", n, len(h), want)
+		}
+		if h != strings.ToLower(h) {
+			t.Errorf("hashString(_, %d): got %q, want lower case", n, h)
+		}
+	}
+}
+
+// ensure individual slaves get records for their IDs and hostnames
+func TestSlaveRecords(t *testing.T) {
+	defer func(f func(string) ([]net.IP, error)) { lookupIP = f }(lookupIP)
+	lookupIP = func(host string) ([]net.IP, error) {
+		return nil, errors.New("no such host")
+	}
+
+	var sj state.State
+	if err := json.Unmarshal([]byte(`{"slaves": [
+		{"id": "20151012-000000-1-5050-1-S0", "hostname": "Agent_1.Example.COM", "pid": "slave(1)@10.0.0.1:5051"},
+		{"id": "20151012-000000-1-5050-1-S1", "hostname": "agent-2.example.com", "pid": "slave(1)@10.0.0.2:5052"},
+		{"id": "20151012-000000-1-5050-1-S2", "hostname": "agent-2.example.com", "pid": "slave(1)@10.0.0.3:5051"}
+	]}`), &sj); err != nil {
 		t.Fatal(err)
 	}
+
+	var rg RecordGenerator
+	rg.resetRecords()
+	rg.SlaveIPs = map[string][]string{}
+	c := NewConfig()
+	rg.slaveRecords(sj, c, labels.RFC1123)
+
+	for i, tt := range []struct {
+		rrs  rrs
+		name string
+		want []string
+	}{
+		{rg.As, "slave.mesos.", []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}},
+		{rg.As, "20151012-000000-1-5050-1-s0.slave.mesos.", []string{"10.0.0.1"}},
+		{rg.As, "20151012-000000-1-5050-1-s2.slave.mesos.", []string{"10.0.0.3"}},
+		{rg.As, "agent-1.example.com.agent.mesos.", []string{"10.0.0.1"}},
+		{rg.As, "agent-2.example.com.agent.mesos.", []string{"10.0.0.2"}}, // first come, first served
+		{rg.SRVs, "_slave._tcp.20151012-000000-1-5050-1-s1.slave.mesos.", []string{
+			"0 0 5052 20151012-000000-1-5050-1-s1.slave.mesos.",
+		}},
+		{rg.SRVs, "_agent._tcp.agent-2.example.com.agent.mesos.", []string{
+			"0 0 5052 agent-2.example.com.agent.mesos.",
+		}},
+		{rg.PTRs, "1.0.0.10.in-addr.arpa.", []string{"20151012-000000-1-5050-1-s0.slave.mesos."}},
+		{rg.PTRs, "3.0.0.10.in-addr.arpa.", []string{"20151012-000000-1-5050-1-s2.slave.mesos."}},
+	} {
+		if got := targets(tt.rrs[tt.name]); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: %q: got: %q, want: %q", i, tt.name, got, tt.want)
+		}
+	}
+}
+
+// ensure the hostnames of slaves are only looked up for IPv6 addresses when
+// configured to
+func TestAgentHostnameIPv6(t *testing.T) {
+	defer func(f func(string) ([]net.IP, error)) { lookupIP = f }(lookupIP)
+	var lookups []string
+	lookupIP = func(host string) ([]net.IP, error) {
+		lookups = append(lookups, host)
+		if host == "agent-1.example.com" {
+			return []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("fd00::1")}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	var sj state.State
+	if err := json.Unmarshal([]byte(`{"slaves": [
+		{"id": "20151012-000000-1-5050-1-S0", "hostname": "agent-1.example.com", "pid": "slave(1)@10.0.0.1:5051"},
+		{"id": "20151012-000000-1-5050-1-S1", "hostname": "agent-2.example.com", "pid": "slave(1)@10.0.0.2:5051"}
+	]}`), &sj); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tt := range []struct {
+		on        bool
+		want      []string
+		hostnames []string
+	}{
+		{false, nil, []string{"10.0.0.1", "10.0.0.2"}},
+		{true, []string{"fd00::1"}, []string{"10.0.0.1", "agent-1.example.com", "10.0.0.2", "agent-2.example.com"}},
+	} {
+		lookups = nil
+		var rg RecordGenerator
+		rg.resetRecords()
+		rg.SlaveIPs = map[string][]string{}
+		c := NewConfig()
+		c.AgentHostnameIPv6 = tt.on
+		rg.slaveRecords(sj, c, labels.RFC1123)
+
+		if got := targets(rg.AAAAs["20151012-000000-1-5050-1-s0.slave.mesos."]); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: got AAAAs %q, want %q", i, got, tt.want)
+		}
+		if !tt.on && len(lookups) > 0 {
+			t.Errorf("test #%d: unexpected lookups of %q", i, lookups)
+		}
+		if got := stateHostnames(sj, tt.on); !reflect.DeepEqual(got, tt.hostnames) {
+			t.Errorf("test #%d: got hostnames %q, want %q", i, got, tt.hostnames)
+		}
+	}
+}
+
+// ensure legacy canonical names are served in compatibility mode
+func TestLegacyTaskNames(t *testing.T) {
+	rg := testRecordGenerator(t, []string{"docker", "mesos", "host"})
+	rgLegacy := testRecordGenerator(t, []string{"docker", "mesos", "host"}, func(c *Config) {
+		c.LegacyTaskNames = true
+	})
+
+	for i, tt := range []struct {
+		rrs  rrs
+		name string
+		want []string
+	}{
+		{rg.As, "liquor-store-17700-0.marathon.mesos.", nil},
+		{rgLegacy.As, "liquor-store-17700-0.marathon.mesos.", []string{"10.3.0.1"}},
+		{rgLegacy.As, "liquor-store-17700-0.marathon.slave.mesos.", []string{"1.2.3.11"}},
+		{rgLegacy.As, "liquor-store-rn76murd-0.marathon.mesos.", []string{"10.3.0.1"}},
+		{rgLegacy.SRVs, "_http._liquor-store._tcp.marathon.mesos.", []string{
+			"0 0 80 liquor-store-rn76murd-0.marathon.mesos.",
+			"0 0 80 liquor-store-ahdjzdbz-1.marathon.mesos.",
+		}},
+		{rgLegacy.PTRs, "1.0.3.10.in-addr.arpa.", []string{"liquor-store-rn76murd-0.marathon.mesos."}},
+	} {
+		if got := targets(tt.rrs[tt.name]); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: %q: got: %q, want: %q", i, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNamingTemplates(t *testing.T) {
+	rg := testRecordGenerator(t, []string{"docker", "mesos", "host"}, func(c *Config) {
+		c.NamingTemplates = NamingTemplates{
+			Task:         "{task}.{label:team}.dc1",
+			TaskService:  "_{task}._{protocol}.{label:team}",
+			PortService:  "_{port}._{task}._{protocol}",
+			Framework:    "{framework}.frameworks",
+			Agent:        "{agent-id}.agents",
+			AgentHost:    "{hostname}.hosts",
+			SlaveAddress: "{name}.on-agent",
+			Cluster:      "{name}.cluster",
+		}
+	})
+
+	for i, tt := range []struct {
+		rrs  rrs
+		name string
+		want []string
+	}{
+		{rg.As, "liquor-store.spirits.dc1.mesos.", []string{"10.3.0.1"}},
+		{rg.As, "liquor-store-rn76murd-0.spirits.dc1.mesos.", []string{"10.3.0.1"}},
+		{rg.As, "liquor-store.spirits.dc1.on-agent.mesos.", []string{"1.2.3.11"}},
+		{rg.As, "liquor-store.spirits.dc1.slave.mesos.", nil},
+		{rg.As, "liquor-store.marathon.mesos.", nil},
+		{rg.As, "nginx.marathon.mesos.", nil}, // no team label
+		{rg.SRVs, "_liquor-store._tcp.spirits.mesos.", []string{
+			"0 0 80 liquor-store-rn76murd-0.spirits.dc1.mesos.",
+			"0 0 443 liquor-store-rn76murd-0.spirits.dc1.mesos.",
+		}},
+		{rg.SRVs, "_https._liquor-store._tcp.mesos.", []string{
+			"0 0 443 liquor-store-rn76murd-0.spirits.dc1.mesos.",
+		}},
+		{rg.SRVs, "_framework._tcp.marathon.frameworks.mesos.", []string{"0 0 25501 marathon.frameworks.mesos."}},
+		{rg.As, "20140803-125133-3041283216-5050-2410-0.agents.mesos.", []string{"1.2.3.11"}},
+		{rg.As, "leader.cluster.mesos.", []string{"144.76.157.37"}},
+		{rg.SRVs, "_leader._tcp.cluster.mesos.", []string{"0 0 5050 leader.cluster.mesos."}},
+		{rg.As, "leader.mesos.", nil},
+	} {
+		if got := targets(tt.rrs[tt.name]); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: %q: got: %q, want: %q", i, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestZoneRecords(t *testing.T) {
+	rg := testRecordGenerator(t, []string{"docker", "mesos", "host"}, func(c *Config) {
+		c.Zones = []Zone{
+			{Domain: "mesos.corp.example", SOARname: c.SOARname},
+			{Domain: "team.example", SOARname: "ns1.team.example.", Frameworks: []string{"marathon"}},
+		}
+	})
+
+	for i, tt := range []struct {
+		rrs  rrs
+		name string
+		want []string
+	}{
+		// aliases hold all records
+		{rg.As, "liquor-store.marathon.mesos.corp.example.", []string{"10.3.0.1", "10.3.0.2"}},
+		{rg.As, "leader.mesos.corp.example.", []string{"144.76.157.37"}},
+		{rg.As, "hello.static.mesos.corp.example.", []string{"120.0.0.1"}},
+		{rg.CNAMEs, "www.static.mesos.corp.example.", []string{"hello.static.mesos.corp.example."}},
+		{rg.SRVs, "_https._liquor-store._tcp.marathon.mesos.corp.example.", []string{
+			"0 0 443 liquor-store-rn76murd-0.marathon.mesos.corp.example.",
+			"0 0 443 liquor-store-ahdjzdbz-1.marathon.mesos.corp.example.",
+		}},
+		{rg.PTRs, "1.0.3.10.in-addr.arpa.", []string{"liquor-store-rn76murd-0.marathon.mesos."}},
+		// restricted zones only hold the records of their frameworks
+		{rg.As, "liquor-store.marathon.team.example.", []string{"10.3.0.1", "10.3.0.2"}},
+		{rg.SRVs, "_framework._tcp.marathon.team.example.", []string{"0 0 25501 marathon.team.example."}},
+		{rg.As, "leader.team.example.", nil},
+		{rg.As, "slave.team.example.", nil},
+		{rg.As, "hello.static.team.example.", nil},
+		{rg.As, "ns1.team.example.", []string{"127.0.0.1"}},
+	} {
+		if got := targets(tt.rrs[tt.name]); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: %q: got: %q, want: %q", i, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAliasRecords(t *testing.T) {
+	sj := fakeState(t)
+	for _, f := range sj.Frameworks {
+		for i, task := range f.Tasks {
+			var names string
+			switch {
+			case strings.HasPrefix(task.ID, "nginx."):
+				names = "web, www.Web.mesos., chronos.marathon, bad_name, web.example."
+			case strings.HasPrefix(task.ID, "liquor-store."):
+				names = "liquor, web"
+			default:
+				continue
+			}
+			f.Tasks[i].Labels = append(task.Labels, state.Label{Key: "DNS_NAMES", Value: names})
+		}
+	}
+
+	c := NewConfig()
+	c.IPSources = []string{"docker", "mesos", "host"}
+	c.EnforceRFC952 = true
+	c.NamesLabel = "DNS_NAMES"
+	var rg RecordGenerator
+	if err := rg.InsertState(sj, c, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tt := range []struct {
+		name string
+		want []string
+	}{
+		{"web.mesos.", []string{"10.3.0.1", "10.3.0.2"}}, // claimed by liquor-store first
+		{"www.web.mesos.", []string{"10.3.0.3"}},
+		{"chronos.marathon.mesos.", []string{"1.2.3.11"}}, // generated
+		{"bad_name.mesos.", nil},
+		{"liquor.mesos.", []string{"10.3.0.1", "10.3.0.2"}}, // shared by app instances
+	} {
+		if got := targets(rg.As[tt.name]); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: %q: got: %q, want: %q", i, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAliasName(t *testing.T) {
+	for i, tt := range []struct {
+		name, want string
+	}{
+		{"web", "web.mesos."},
+		{"API.search", "api.search.mesos."},
+		{"api.mesos.", "api.mesos."},
+		{"mesos.", ""},
+		{"api.example.", ""},
+		{"api..search", ""},
+		{"-api", ""},
+		{"api_v1", ""},
+	} {
+		got, err := aliasName(tt.name, "mesos", labels.RFC1123)
+		if got != tt.want || (err == nil) != (tt.want != "") {
+			t.Errorf("test #%d: %q: got %q, %v; want %q", i, tt.name, got, err, tt.want)
+		}
+	}
+}
+
+// ensure colliding canonical names are disambiguated
+func TestCanonicalNameCollision(t *testing.T) {
+	var rg RecordGenerator
+	name := func(task string) func(string) string {
+		return func(hash string) string { return task + "-" + hash + "-0.marathon" }
+	}
+
+	first := rg.canonicalName("foo.1", 1, name("foo"))
+	if got := rg.canonicalName("foo.1", 1, name("foo")); got != first {
+		t.Errorf("same task: got %q, want %q", got, first)
+	}
+
+	// claim the name the next task would get
+	hash := hashString("foo.2", 1)
+	rg.claimTaskName("foo-"+hash+"-0.marathon", "foo.3")
+	if got, want := rg.canonicalName("foo.2", 1, name("foo")), "foo-"+hashString("foo.2", 2)+"-0.marathon"; got != want {
+		t.Errorf("extended hash: got %q, want %q", got, want)
+	}
+
+	// exhaust all hash lengths
+	for n := 1; n <= maxHashLength; n++ {
+		rg.claimTaskName("bar-"+hashString("bar.2", n)+"-0.marathon", "bar.1")
+	}
+	if got, want := rg.canonicalName("bar.2", 1, name("bar")), "bar-"+hashString("bar.2", maxHashLength)+"1-0.marathon"; got != want {
+		t.Errorf("sequence number: got %q, want %q", got, want)
+	}
+}
+
+// targets returns the presentation format of the data of the given records.
+func targets(rs []Record) []string {
+	if rs == nil {
+		return nil
+	}
+	ss := make([]string, len(rs))
+	for i, r := range rs {
+		ss[i] = r.String()
+	}
+	return ss
+}
+
+// values returns the presentation format of the data of all records in rs.
+func values(rs rrs) map[string][]string {
+	m := make(map[string][]string, len(rs))
+	for name, r := range rs {
+		m[name] = targets(r)
+	}
+	return m
 }
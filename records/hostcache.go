@@ -0,0 +1,149 @@
+package records
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mesosphere/mesos-dns/logging"
+	"github.com/mesosphere/mesos-dns/records/state"
+)
+
+// HostCache caches the addresses the hostnames of frameworks and slaves
+// resolve to across reloads. Expired hostnames are resolved concurrently, up
+// to a limit, and hostnames which fail to resolve keep their last known
+// addresses. It's safe for concurrent use.
+type HostCache struct {
+	ttl         time.Duration // of resolved hostnames
+	negativeTTL time.Duration // of failed lookups
+	concurrency int           // of lookups
+
+	mu      sync.Mutex
+	entries map[string]hostEntry
+	round   int // of Resolve calls
+}
+
+// hostEntry holds the last known addresses of a hostname.
+type hostEntry struct {
+	addrs   []string // nil if the hostname never resolved
+	expires time.Time
+	round   int // of the last lookup
+}
+
+// NewHostCache returns a HostCache which caches the addresses of hostnames for
+// the given TTL and failed lookups for the given negative TTL, and resolves at
+// most the given number of hostnames concurrently.
+func NewHostCache(ttl, negativeTTL time.Duration, concurrency int) *HostCache {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &HostCache{
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		concurrency: concurrency,
+		entries:     map[string]hostEntry{},
+	}
+}
+
+// Resolve looks up the given hostnames whose entries expired, concurrently,
+// and drops the entries of all other hostnames. Hostnames resolved by Resolve
+// aren't looked up again by Lookup until the next call to Resolve, regardless
+// of the TTL.
+func (hc *HostCache) Resolve(hostnames []string) {
+	hc.mu.Lock()
+	hc.round++
+	now := timeNow()
+	keep := make(map[string]hostEntry, len(hostnames))
+	var expired []string
+	for _, host := range hostnames {
+		if _, ok := keep[host]; ok || host == "" || net.ParseIP(host) != nil {
+			continue
+		}
+		e, ok := hc.entries[host]
+		keep[host] = e
+		if !ok || !now.Before(e.expires) {
+			expired = append(expired, host)
+		}
+	}
+	hc.entries = keep
+	hc.mu.Unlock()
+
+	sem := make(chan struct{}, hc.concurrency)
+	var wg sync.WaitGroup
+	for _, host := range expired {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(host string) {
+			defer func() { <-sem; wg.Done() }()
+			_, _ = hc.refresh(host)
+		}(host)
+	}
+	wg.Wait()
+}
+
+// Lookup returns the addresses of the given hostname and whether there are
+// any, resolving it if its entry expired.
+func (hc *HostCache) Lookup(hostname string) ([]string, bool) {
+	if ip := net.ParseIP(hostname); ip != nil {
+		return []string{ip.String()}, true
+	}
+
+	hc.mu.Lock()
+	e, ok := hc.entries[hostname]
+	fresh := ok && (e.round == hc.round || timeNow().Before(e.expires))
+	hc.mu.Unlock()
+
+	if fresh {
+		logging.CurLog.HostCacheHits.Inc()
+		return e.addrs, len(e.addrs) > 0
+	}
+	return hc.refresh(hostname)
+}
+
+// refresh looks up the given hostname and updates its entry. If the lookup
+// fails, the hostname keeps its last known addresses, if any, and is looked up
+// again after the negative TTL.
+func (hc *HostCache) refresh(hostname string) ([]string, bool) {
+	logging.CurLog.HostLookups.Inc()
+	addrs, err := lookupAddrs(hostname)
+	now := timeNow()
+
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	e := hc.entries[hostname]
+	e.round = hc.round
+	if err == nil {
+		e.addrs, e.expires = addrs, now.Add(hc.ttl)
+		hc.entries[hostname] = e
+		return addrs, true
+	}
+
+	logging.CurLog.HostLookupFailures.Inc()
+	e.expires = now.Add(hc.negativeTTL)
+	hc.entries[hostname] = e
+	if len(e.addrs) == 0 {
+		logging.Error.Printf("cannot translate hostname %q into an ip address: %v", hostname, err)
+		return nil, false
+	}
+	logging.Error.Printf("cannot translate hostname %q into an ip address: %v; using last known addresses %v",
+		hostname, err, e.addrs)
+	return e.addrs, true
+}
+
+// stateHostnames returns the hostnames of the frameworks and slaves of the
+// given state: the PID hosts of the slaves, along with their hostnames if
+// they're looked up too.
+func stateHostnames(sj state.State, slaveHostnames bool) []string {
+	hosts := make([]string, 0, len(sj.Frameworks)+2*len(sj.Slaves))
+	for _, f := range sj.Frameworks {
+		host, _ := f.HostPort()
+		hosts = append(hosts, host)
+	}
+	for _, slave := range sj.Slaves {
+		hosts = append(hosts, slave.PID.Host)
+		if slaveHostnames {
+			hosts = append(hosts, slave.Hostname)
+		}
+	}
+	return hosts
+}
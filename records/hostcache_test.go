@@ -0,0 +1,122 @@
+package records
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHostCache(t *testing.T) {
+	defer func(f func(string) ([]net.IP, error)) { lookupIP = f }(lookupIP)
+	defer func(now func() time.Time) { timeNow = now }(timeNow)
+
+	var (
+		mu      sync.Mutex
+		now     = time.Unix(1500000000, 0)
+		ips     = map[string]string{"a.example.com": "10.0.0.1", "b.example.com": "10.0.0.2"}
+		lookups = map[string]int{}
+	)
+	timeNow = func() time.Time { mu.Lock(); defer mu.Unlock(); return now }
+	lookupIP = func(host string) ([]net.IP, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		lookups[host]++
+		if ip, ok := ips[host]; ok {
+			return []net.IP{net.ParseIP(ip)}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	set := func(host, ip string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if ip == "" {
+			delete(ips, host)
+		} else {
+			ips[host] = ip
+		}
+	}
+	advance := func(d time.Duration) { mu.Lock(); defer mu.Unlock(); now = now.Add(d) }
+
+	hc := NewHostCache(time.Minute, 10*time.Second, 2)
+	hosts := []string{"a.example.com", "b.example.com", "10.0.0.3", ""}
+
+	for i, tt := range []struct {
+		step    func()
+		host    string
+		want    []string
+		ok      bool
+		lookups int // of host so far
+	}{
+		// resolved once by Resolve, then served from the cache
+		{func() { hc.Resolve(hosts) }, "a.example.com", []string{"10.0.0.1"}, true, 1},
+		{func() { hc.Resolve(hosts) }, "a.example.com", []string{"10.0.0.1"}, true, 1},
+		// IP literals are never looked up
+		{nil, "10.0.0.3", []string{"10.0.0.3"}, true, 0},
+		// looked up again once the TTL expires
+		{func() { set("a.example.com", "10.0.0.9"); advance(time.Minute); hc.Resolve(hosts) },
+			"a.example.com", []string{"10.0.0.9"}, true, 2},
+		// transient failures keep the last known addresses...
+		{func() { set("a.example.com", ""); advance(time.Minute); hc.Resolve(hosts) },
+			"a.example.com", []string{"10.0.0.9"}, true, 3},
+		// ...and aren't retried before the negative TTL expires
+		{func() { advance(5 * time.Second); hc.Resolve(hosts) }, "a.example.com", []string{"10.0.0.9"}, true, 3},
+		{func() { set("a.example.com", "10.0.0.1"); advance(5 * time.Second); hc.Resolve(hosts) },
+			"a.example.com", []string{"10.0.0.1"}, true, 4},
+		// hostnames which never resolved have no addresses
+		{func() { hc.Resolve(append(hosts, "c.example.com")) }, "c.example.com", nil, false, 1},
+		{nil, "c.example.com", nil, false, 1},
+		// hostnames left out of Resolve are dropped and looked up on demand
+		{func() { hc.Resolve(hosts[:1]) }, "b.example.com", []string{"10.0.0.2"}, true, 4},
+	} {
+		if tt.step != nil {
+			tt.step()
+		}
+		got, ok := hc.Lookup(tt.host)
+		if !reflect.DeepEqual(got, tt.want) || ok != tt.ok {
+			t.Errorf("test #%d: got %v, %t, want %v, %t", i, got, ok, tt.want, tt.ok)
+		}
+		mu.Lock()
+		if n := lookups[tt.host]; n != tt.lookups {
+			t.Errorf("test #%d: got %d lookups of %q, want %d", i, n, tt.host, tt.lookups)
+		}
+		mu.Unlock()
+	}
+}
+
+func TestHostCacheConcurrency(t *testing.T) {
+	defer func(f func(string) ([]net.IP, error)) { lookupIP = f }(lookupIP)
+
+	var mu sync.Mutex
+	var cur, max int
+	lookupIP = func(host string) ([]net.IP, error) {
+		mu.Lock()
+		if cur++; cur > max {
+			max = cur
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		cur--
+		mu.Unlock()
+		return []net.IP{net.ParseIP("10.0.0.1")}, nil
+	}
+
+	hc := NewHostCache(time.Minute, time.Minute, 3)
+	hosts := make([]string, 10)
+	for i := range hosts {
+		hosts[i] = string('a'+rune(i)) + ".example.com"
+	}
+	hc.Resolve(hosts)
+
+	if max != 3 {
+		t.Errorf("got %d concurrent lookups, want 3", max)
+	}
+	for _, host := range hosts {
+		if _, ok := hc.Lookup(host); !ok {
+			t.Errorf("%q wasn't resolved", host)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package records
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/mesosphere/mesos-dns/logging"
+	"github.com/mesosphere/mesos-dns/records/labels"
+	"github.com/mesosphere/mesos-dns/records/state"
+)
+
+// NamingTemplates holds the templates of the names of each record family,
+// relative to the domain. Templates consist of literal labels and variables
+// in braces, e.g. "{task}.{framework}.{label:env}.dc1", which are expanded
+// when generating records.
+type NamingTemplates struct {
+	// Task names the A, AAAA and TXT records of tasks (default
+	// "{task}.{framework}"). Variables: task, framework, agent-id and
+	// label:<key> for the value of a task or DiscoveryInfo label.
+	Task string
+	// TaskService names the SRV records of tasks (default
+	// "_{task}._{protocol}.{framework}"). Variables: those of Task and
+	// protocol.
+	TaskService string
+	// PortService names the SRV records of named task ports (default
+	// "_{port}._{task}._{protocol}.{framework}"). Variables: those of
+	// TaskService and port.
+	PortService string
+	// Framework names the A, AAAA and SRV records of frameworks (default
+	// "{framework}"). Variables: framework.
+	Framework string
+	// Agent names the records of individual agents by ID (default
+	// "{agent-id}.slave"). Variables: agent-id and hostname.
+	Agent string
+	// AgentHost names the records of individual agents by hostname
+	// (default "{hostname}.agent"). Variables: agent-id and hostname.
+	AgentHost string
+	// SlaveAddress names the records of tasks resolving to the addresses of
+	// their agents (default "{name}.slave"), where name is the name of the
+	// corresponding A or SRV record of the task, e.g. "{task}.{framework}"
+	// expanded. Variables: name.
+	SlaveAddress string
+	// Cluster names the records of the leader, masters and agents as a whole
+	// (default "{name}"), where name is one of leader, master, masterN, slave
+	// and their SRV names, e.g. _leader._tcp. Variables: name.
+	Cluster string
+}
+
+// DefaultNamingTemplates returns the templates of the default naming scheme.
+func DefaultNamingTemplates() NamingTemplates {
+	return NamingTemplates{
+		Task:         "{task}.{framework}",
+		TaskService:  "_{task}._{protocol}.{framework}",
+		PortService:  "_{port}._{task}._{protocol}.{framework}",
+		Framework:    "{framework}",
+		Agent:        "{agent-id}.slave",
+		AgentHost:    "{hostname}.agent",
+		SlaveAddress: "{name}.slave",
+		Cluster:      "{name}",
+	}
+}
+
+// labelVar is the prefix of template variables holding label values.
+const labelVar = "label:"
+
+// render expands the variables of the given template with the given values.
+// It returns an error if a variable has no or an empty value.
+func render(tmpl string, vars map[string]string) (string, error) {
+	var b bytes.Buffer
+	for {
+		i := strings.IndexByte(tmpl, '{')
+		if i == -1 {
+			b.WriteString(tmpl)
+			return strings.ToLower(b.String()), nil
+		}
+		j := strings.IndexByte(tmpl[i:], '}')
+		if j == -1 {
+			return "", fmt.Errorf("unterminated variable in template %q", tmpl)
+		}
+		key := tmpl[i+1 : i+j]
+		value := vars[key]
+		if value == "" {
+			return "", fmt.Errorf("no value for variable {%s}", key)
+		}
+		b.WriteString(tmpl[:i])
+		b.WriteString(value)
+		tmpl = tmpl[i+j+1:]
+	}
+}
+
+// templateVars returns the variables the given template refers to.
+func templateVars(tmpl string) ([]string, error) {
+	var vars []string
+	for {
+		i := strings.IndexByte(tmpl, '{')
+		if i == -1 {
+			if strings.IndexByte(tmpl, '}') != -1 {
+				return nil, fmt.Errorf("unbalanced braces")
+			}
+			return vars, nil
+		}
+		j := strings.IndexByte(tmpl[i:], '}')
+		if j == -1 || strings.IndexByte(tmpl[:i], '}') != -1 {
+			return nil, fmt.Errorf("unbalanced braces")
+		}
+		vars = append(vars, tmpl[i+1:i+j])
+		tmpl = tmpl[i+j+1:]
+	}
+}
+
+// qualifiedName renders the given template with the given variables as a
+// fully qualified name within the given domain.
+func qualifiedName(tmpl, domain string, vars map[string]string) (string, error) {
+	name, err := render(tmpl, vars)
+	if err != nil {
+		return "", err
+	}
+	return name + "." + domain + ".", nil
+}
+
+// clusterName returns the fully qualified name of the cluster-wide record of
+// the given name, e.g. "leader".
+func clusterName(tmpls NamingTemplates, domain, name string) string {
+	qname, err := qualifiedName(tmpls.Cluster, domain, map[string]string{"name": name})
+	if err != nil {
+		logging.Error.Printf("invalid Cluster naming template: %v", err)
+		return name + "." + domain + "."
+	}
+	return qname
+}
+
+// slaveAddressName returns the fully qualified name of the records of a task
+// resolving to the addresses of its slave, given the name of the corresponding
+// record relative to the domain, e.g. "web.marathon".
+func slaveAddressName(tmpls NamingTemplates, domain, name string) string {
+	qname, err := qualifiedName(tmpls.SlaveAddress, domain, map[string]string{"name": name})
+	if err != nil {
+		logging.Error.Printf("invalid SlaveAddress naming template: %v", err)
+		return name + ".slave." + domain + "."
+	}
+	return qname
+}
+
+// taskVars returns the template variables of the given task: its name, the
+// name of its framework, the ID of its agent and the values of its task and
+// DiscoveryInfo labels, with task labels taking precedence.
+func taskVars(task *state.Task, taskName, fname string, spec labels.Func) map[string]string {
+	vars := map[string]string{
+		"task":      taskName,
+		"framework": fname,
+		"agent-id":  labels.RFC1123(task.SlaveID),
+	}
+	for _, l := range task.DiscoveryInfo.Labels.Labels {
+		vars[labelVar+l.Key] = spec(l.Value)
+	}
+	for _, l := range task.Labels {
+		vars[labelVar+l.Key] = spec(l.Value)
+	}
+	return vars
+}
+
+// withVars returns a copy of the given variables with the given key and value
+// pairs set.
+func withVars(vars map[string]string, kvs ...string) map[string]string {
+	vs := make(map[string]string, len(vars)+len(kvs)/2)
+	for k, v := range vars {
+		vs[k] = v
+	}
+	for i := 0; i+1 < len(kvs); i += 2 {
+		vs[kvs[i]] = kvs[i+1]
+	}
+	return vs
+}
@@ -0,0 +1,24 @@
+package records
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	vars := map[string]string{"task": "web", "framework": "marathon", "label:env": "prod", "empty": ""}
+	for i, tt := range []struct {
+		tmpl, want string
+		ok         bool
+	}{
+		{"{task}.{framework}", "web.marathon", true},
+		{"{task}.{framework}.{label:env}.DC1", "web.marathon.prod.dc1", true},
+		{"_{task}._tcp", "_web._tcp", true},
+		{"static", "static", true},
+		{"{task}.{label:team}", "", false},
+		{"{task}.{empty}", "", false},
+		{"{task", "", false},
+	} {
+		got, err := render(tt.tmpl, vars)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("test #%d: %q: got %q, %v; want %q", i, tt.tmpl, got, err, tt.want)
+		}
+	}
+}
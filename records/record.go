@@ -0,0 +1,88 @@
+package records
+
+import "strconv"
+
+// Class classifies records by the kind of object they originate from.
+type Class string
+
+// Record classes.
+const (
+	TaskClass      Class = "task"
+	FrameworkClass Class = "framework"
+	SlaveClass     Class = "slave"
+	MasterClass    Class = "master"
+	ListenerClass  Class = "mesos-dns"
+	StaticClass    Class = "static"
+)
+
+// Record is a single DNS resource record generated from the Mesos state or
+// from a static entry. The name it answers for is the key it's stored under.
+type Record struct {
+	// Type is the record type, one of RecordTypes.
+	Type string `json:"type"`
+	// Target holds the address of A and AAAA records, the text of TXT records
+	// and the target name of all other record types.
+	Target string `json:"target"`
+	// Port is the port of SRV records.
+	Port uint16 `json:"port,omitempty"`
+	// PortName is the DiscoveryInfo name of the port of SRV records, if any.
+	PortName string `json:"port_name,omitempty"`
+	// Priority is the priority of SRV records and the preference of MX records.
+	Priority uint16 `json:"priority,omitempty"`
+	// Weight is the weight of SRV records.
+	Weight uint16 `json:"weight,omitempty"`
+	// TTL overrides the configured TTL if non-zero.
+	TTL uint32 `json:"ttl,omitempty"`
+
+	// Visibility is the DiscoveryInfo visibility of the task the record
+	// originates from, i.e. "CLUSTER" or "EXTERNAL", if any.
+	Visibility string `json:"visibility,omitempty"`
+	// Unhealthy is set on records of tasks failing their health checks.
+	Unhealthy bool `json:"unhealthy,omitempty"`
+
+	// Class, TaskID, FrameworkID and SlaveID identify the object the record
+	// originates from.
+	Class       Class  `json:"class,omitempty"`
+	TaskID      string `json:"task_id,omitempty"`
+	FrameworkID string `json:"framework_id,omitempty"`
+	SlaveID     string `json:"slave_id,omitempty"`
+}
+
+// String returns the record data in its RFC 1035 presentation format, e.g.
+// "0 0 80 nginx.marathon.mesos." for SRV records.
+func (r Record) String() string {
+	switch r.Type {
+	case "SRV":
+		return strconv.Itoa(int(r.Priority)) + " " + strconv.Itoa(int(r.Weight)) +
+			" " + strconv.Itoa(int(r.Port)) + " " + r.Target
+	case "MX":
+		return strconv.Itoa(int(r.Priority)) + " " + r.Target
+	default:
+		return r.Target
+	}
+}
+
+// sameData returns true if both records hold the same record data,
+// regardless of their origin.
+func (r Record) sameData(o Record) bool {
+	return r.Type == o.Type &&
+		r.Target == o.Target &&
+		r.Port == o.Port &&
+		r.Priority == o.Priority &&
+		r.Weight == o.Weight
+}
+
+// restrictiveness ranks visibilities by how restrictive they are. Records
+// without one are visible to everyone, like those of EXTERNAL tasks.
+var restrictiveness = map[string]int{"EXTERNAL": 0, "CLUSTER": 1, "FRAMEWORK": 2}
+
+// merge returns the record merged with another one holding the same data, so
+// that it answers for both of their origins: it's only unhealthy if both are,
+// and has the least restrictive visibility of both.
+func (r Record) merge(o Record) Record {
+	r.Unhealthy = r.Unhealthy && o.Unhealthy
+	if restrictiveness[o.Visibility] < restrictiveness[r.Visibility] {
+		r.Visibility = o.Visibility
+	}
+	return r
+}
@@ -0,0 +1,39 @@
+package records
+
+import "testing"
+
+func TestRecordString(t *testing.T) {
+	for i, tt := range []struct {
+		r    Record
+		want string
+	}{
+		{Record{Type: "A", Target: "1.2.3.4"}, "1.2.3.4"},
+		{Record{Type: "TXT", Target: "owner=infra"}, "owner=infra"},
+		{Record{Type: "SRV", Target: "nginx.marathon.mesos.", Port: 80}, "0 0 80 nginx.marathon.mesos."},
+		{Record{Type: "SRV", Target: "nginx.marathon.mesos.", Port: 80, Priority: 1, Weight: 2}, "1 2 80 nginx.marathon.mesos."},
+		{Record{Type: "MX", Target: "mail.mesos.", Priority: 10}, "10 mail.mesos."},
+	} {
+		if got := tt.r.String(); got != tt.want {
+			t.Errorf("test #%d: got %q, want %q", i, got, tt.want)
+		}
+	}
+}
+
+func TestRecordMerge(t *testing.T) {
+	for i, tt := range []struct {
+		r, o, want Record
+	}{
+		{Record{Unhealthy: true}, Record{Unhealthy: true}, Record{Unhealthy: true}},
+		{Record{Unhealthy: true}, Record{}, Record{}},
+		{Record{}, Record{Unhealthy: true}, Record{}},
+		{Record{Visibility: "CLUSTER"}, Record{Visibility: "EXTERNAL"}, Record{Visibility: "EXTERNAL"}},
+		{Record{Visibility: "EXTERNAL"}, Record{Visibility: "CLUSTER"}, Record{Visibility: "EXTERNAL"}},
+		{Record{Visibility: "FRAMEWORK"}, Record{Visibility: "CLUSTER"}, Record{Visibility: "CLUSTER"}},
+		{Record{Visibility: "CLUSTER"}, Record{}, Record{}},
+		{Record{Visibility: "CLUSTER", Unhealthy: true}, Record{Visibility: "CLUSTER"}, Record{Visibility: "CLUSTER"}},
+	} {
+		if got := tt.r.merge(tt.o); got != tt.want {
+			t.Errorf("test #%d: got %+v, want %+v", i, got, tt.want)
+		}
+	}
+}
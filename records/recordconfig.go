@@ -13,11 +13,13 @@ type StaticEntryConfig struct {
 	Entries []StaticEntry
 }
 
-// StaticEntry represents a tuple of (FQDN, RecordType, VALUE)
+// StaticEntry represents a tuple of (FQDN, RecordType, VALUE) with an
+// optional TTL overriding the configured one
 type StaticEntry struct {
 	Fqdn  string
 	Type  string
 	Value string
+	TTL   uint32
 }
 
 // ParseStaticConfig attempts to parse Entries from the passed jsonFile
@@ -0,0 +1,65 @@
+package records
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ReverseZones returns the names of the in-addr.arpa. and ip6.arpa. zones
+// covering the given CIDRs. Prefixes which don't fall on a label boundary
+// (octets for IPv4, nibbles for IPv6) are expanded into all the zones of the
+// next longer prefix that does.
+func ReverseZones(cidrs []string) ([]string, error) {
+	var zones []string
+	for _, cidr := range cidrs {
+		zs, err := reverseZones(cidr)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, zs...)
+	}
+	return unique(zones), nil
+}
+
+// reverseZones returns the names of the reverse zones covering a single CIDR.
+func reverseZones(cidr string) ([]string, error) {
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reverse zone CIDR %q: %v", cidr, err)
+	}
+
+	ip := ipnet.IP.To4()
+	step := 8
+	if ip == nil {
+		ip = ipnet.IP.To16()
+		step = 4
+	}
+	ones, bits := ipnet.Mask.Size()
+
+	// round the prefix length up to the next label boundary
+	target := (ones + step - 1) / step * step
+	n := 1 << uint(target-ones)
+
+	zones := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		zip := make(net.IP, len(ip))
+		copy(zip, ip)
+		if n > 1 {
+			// all expanded bits live in the same byte
+			shift := uint(7 - (target-1)%8)
+			zip[ones/8] |= byte(i << shift)
+		}
+
+		arpa, err := dns.ReverseAddr(zip.String())
+		if err != nil {
+			return nil, fmt.Errorf("invalid reverse zone CIDR %q: %v", cidr, err)
+		}
+		// drop the labels of the host part
+		labels := strings.SplitN(arpa, ".", (bits-target)/step+1)
+		zones = append(zones, labels[len(labels)-1])
+	}
+	return zones, nil
+}
@@ -0,0 +1,39 @@
+package records
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReverseZones(t *testing.T) {
+	for i, tt := range []struct {
+		cidrs []string
+		want  []string
+		err   bool
+	}{
+		{nil, nil, false},
+		{[]string{"10.0.0.0/8"}, []string{"10.in-addr.arpa."}, false},
+		{[]string{"192.168.1.0/24"}, []string{"1.168.192.in-addr.arpa."}, false},
+		{[]string{"10.1.2.3/32"}, []string{"3.2.1.10.in-addr.arpa."}, false},
+		{[]string{"0.0.0.0/0"}, []string{"in-addr.arpa."}, false},
+		{[]string{"10.16.0.0/14"}, []string{
+			"16.10.in-addr.arpa.",
+			"17.10.in-addr.arpa.",
+			"18.10.in-addr.arpa.",
+			"19.10.in-addr.arpa.",
+		}, false},
+		{[]string{"10.0.0.0/8", "10.0.0.0/8"}, []string{"10.in-addr.arpa."}, false},
+		{[]string{"2001:db8::/32"}, []string{"8.b.d.0.1.0.0.2.ip6.arpa."}, false},
+		{[]string{"fd00::/7"}, []string{"c.f.ip6.arpa.", "d.f.ip6.arpa."}, false},
+		{[]string{"10.0.0.0"}, nil, true},
+		{[]string{"bogus/8"}, nil, true},
+	} {
+		got, err := ReverseZones(tt.cidrs)
+		if (err != nil) != tt.err {
+			t.Errorf("test #%d: unexpected error state: %v", i, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) && !(len(got) == 0 && len(tt.want) == 0) {
+			t.Errorf("test #%d: got %q, want %q", i, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Event is an event of the SUBSCRIBE call of the v1 Mesos operator API.
+type Event struct {
+	Type string
+
+	// HeartbeatInterval is the interval of the HEARTBEAT events of the
+	// subscription, as announced by its SUBSCRIBED event
+	HeartbeatInterval time.Duration
+
+	event operatorEvent
+}
+
+// operatorEvent holds an event as defined in the v1 Mesos operator API,
+// limited to the events which affect a State.
+type operatorEvent struct {
+	Type       string `json:"type"`
+	Subscribed *struct {
+		GetState                 operatorState `json:"get_state"`
+		HeartbeatIntervalSeconds float64       `json:"heartbeat_interval_seconds"`
+	} `json:"subscribed"`
+	TaskAdded *struct {
+		Task operatorTask `json:"task"`
+	} `json:"task_added"`
+	TaskUpdated *struct {
+		FrameworkID operatorID     `json:"framework_id"`
+		Status      operatorStatus `json:"status"`
+		State       string         `json:"state"`
+	} `json:"task_updated"`
+	FrameworkAdded *struct {
+		Framework operatorFramework `json:"framework"`
+	} `json:"framework_added"`
+	FrameworkUpdated *struct {
+		Framework operatorFramework `json:"framework"`
+	} `json:"framework_updated"`
+	FrameworkRemoved *struct {
+		FrameworkInfo operatorFrameworkInfo `json:"framework_info"`
+	} `json:"framework_removed"`
+	AgentAdded *struct {
+		Agent operatorAgent `json:"agent"`
+	} `json:"agent_added"`
+	AgentRemoved *struct {
+		AgentID operatorID `json:"agent_id"`
+	} `json:"agent_removed"`
+}
+
+// ParseEvent parses an event of the SUBSCRIBE call of the v1 Mesos operator
+// API.
+func ParseEvent(data []byte) (Event, error) {
+	var e Event
+	if err := json.Unmarshal(data, &e.event); err != nil {
+		return e, err
+	}
+	e.Type = e.event.Type
+	if e.Type == "SUBSCRIBED" {
+		if e.event.Subscribed == nil {
+			return e, fmt.Errorf("missing subscribed in %s event", e.Type)
+		}
+		e.HeartbeatInterval = time.Duration(e.event.Subscribed.HeartbeatIntervalSeconds * float64(time.Second))
+	}
+	return e, nil
+}
+
+// Cluster holds the state of a cluster maintained from the events of the
+// SUBSCRIBE call of the v1 Mesos operator API. It isn't safe for concurrent
+// use.
+type Cluster struct {
+	leader     string
+	frameworks map[string]Framework // without tasks
+	slaves     map[string]Slave
+	tasks      map[string]map[string]Task // by framework and task ID
+}
+
+// NewCluster returns an empty Cluster whose leader is the given master PID.
+func NewCluster(leader string) *Cluster {
+	return &Cluster{
+		leader:     leader,
+		frameworks: map[string]Framework{},
+		slaves:     map[string]Slave{},
+		tasks:      map[string]map[string]Task{},
+	}
+}
+
+// Apply applies the given event and returns whether it changed the state.
+// SUBSCRIBED events replace the whole state. Tasks are dropped once they
+// reach a terminal state.
+func (c *Cluster) Apply(e Event) (bool, error) {
+	switch ev := e.event; ev.Type {
+	case "SUBSCRIBED":
+		return true, c.reset(&ev.Subscribed.GetState)
+	case "TASK_ADDED":
+		if ev.TaskAdded == nil {
+			break
+		}
+		c.addTask(ev.TaskAdded.Task.task())
+		return true, nil
+	case "TASK_UPDATED":
+		if ev.TaskUpdated == nil {
+			break
+		}
+		tasks := c.tasks[ev.TaskUpdated.FrameworkID.Value]
+		t, ok := tasks[ev.TaskUpdated.Status.TaskID.Value]
+		if !ok {
+			return false, nil
+		}
+		t.State = ev.TaskUpdated.State
+		t.Statuses = append(t.Statuses[:len(t.Statuses):len(t.Statuses)], ev.TaskUpdated.Status.status())
+		if terminal(t.State) {
+			delete(tasks, t.ID)
+		} else {
+			tasks[t.ID] = t
+		}
+		return true, nil
+	case "FRAMEWORK_ADDED", "FRAMEWORK_UPDATED":
+		f := ev.FrameworkAdded
+		if f == nil {
+			f = ev.FrameworkUpdated
+		}
+		if f == nil {
+			break
+		}
+		c.frameworks[f.Framework.FrameworkInfo.ID.Value] = f.Framework.framework()
+		return true, nil
+	case "FRAMEWORK_REMOVED":
+		if ev.FrameworkRemoved == nil {
+			break
+		}
+		id := ev.FrameworkRemoved.FrameworkInfo.ID.Value
+		delete(c.frameworks, id)
+		delete(c.tasks, id)
+		return true, nil
+	case "AGENT_ADDED":
+		if ev.AgentAdded == nil {
+			break
+		}
+		slave, err := ev.AgentAdded.Agent.slave()
+		if err != nil {
+			return false, err
+		}
+		c.slaves[slave.ID] = slave
+		return true, nil
+	case "AGENT_REMOVED":
+		if ev.AgentRemoved == nil {
+			break
+		}
+		delete(c.slaves, ev.AgentRemoved.AgentID.Value)
+		return true, nil
+	default:
+		return false, nil
+	}
+	return false, fmt.Errorf("malformed %s event", e.Type)
+}
+
+// reset replaces the state with the given one.
+func (c *Cluster) reset(gs *operatorState) error {
+	sj, err := gs.state(c.leader)
+	if err != nil {
+		return err
+	}
+
+	*c = *NewCluster(c.leader)
+	for _, f := range sj.Frameworks {
+		for _, t := range append(f.Tasks, f.UnreachableTasks...) {
+			c.addTask(t)
+		}
+		f.Tasks, f.UnreachableTasks = nil, nil
+		c.frameworks[f.ID] = f
+	}
+	for _, slave := range sj.Slaves {
+		c.slaves[slave.ID] = slave
+	}
+	return nil
+}
+
+// addTask adds or replaces the given task.
+func (c *Cluster) addTask(t Task) {
+	tasks, ok := c.tasks[t.FrameworkID]
+	if !ok {
+		tasks = map[string]Task{}
+		c.tasks[t.FrameworkID] = tasks
+	}
+	tasks[t.ID] = t
+}
+
+// State returns the State of the cluster, with its frameworks, tasks and
+// slaves sorted by ID. Tasks of unknown frameworks are left out.
+func (c *Cluster) State() State {
+	sj := State{Leader: c.leader}
+
+	ids := make([]string, 0, len(c.frameworks))
+	for id := range c.frameworks {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		f := c.frameworks[id]
+		tasks := c.tasks[id]
+		taskIDs := make([]string, 0, len(tasks))
+		for taskID := range tasks {
+			taskIDs = append(taskIDs, taskID)
+		}
+		sort.Strings(taskIDs)
+		for _, taskID := range taskIDs {
+			if t := tasks[taskID]; t.State == "TASK_UNREACHABLE" {
+				f.UnreachableTasks = append(f.UnreachableTasks, t)
+			} else {
+				f.Tasks = append(f.Tasks, t)
+			}
+		}
+		sj.Frameworks = append(sj.Frameworks, f)
+	}
+
+	ids = make([]string, 0, len(c.slaves))
+	for id := range c.slaves {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		sj.Slaves = append(sj.Slaves, c.slaves[id])
+	}
+	return sj
+}
+
+// terminal returns whether the given task state is terminal.
+func terminal(state string) bool {
+	switch state {
+	case "TASK_FINISHED", "TASK_FAILED", "TASK_KILLED", "TASK_ERROR",
+		"TASK_LOST", "TASK_DROPPED", "TASK_GONE", "TASK_GONE_BY_OPERATOR":
+		return true
+	default:
+		return false
+	}
+}
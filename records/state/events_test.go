@@ -0,0 +1,200 @@
+package state_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	. "github.com/mesosphere/mesos-dns/records/state"
+)
+
+func TestParseEvent(t *testing.T) {
+	for i, tt := range []struct {
+		data      string
+		typ       string
+		heartbeat time.Duration
+		err       bool
+	}{
+		{`{"type": "SUBSCRIBED", "subscribed": {"get_state": {}, "heartbeat_interval_seconds": 1.5}}`, "SUBSCRIBED", 1500 * time.Millisecond, false},
+		{`{"type": "HEARTBEAT"}`, "HEARTBEAT", 0, false},
+		{`{"type": "TASK_ADDED", "task_added": {"task": {}}}`, "TASK_ADDED", 0, false},
+		{`{"type": "SUBSCRIBED"}`, "SUBSCRIBED", 0, true},
+		{`{"type": 1}`, "", 0, true},
+	} {
+		e, err := ParseEvent([]byte(tt.data))
+		if (err != nil) != tt.err {
+			t.Errorf("test #%d: got err: %v, want err: %t", i, err, tt.err)
+		}
+		if err == nil && (e.Type != tt.typ || e.HeartbeatInterval != tt.heartbeat) {
+			t.Errorf("test #%d: got %q every %v, want %q every %v", i, e.Type, e.HeartbeatInterval, tt.typ, tt.heartbeat)
+		}
+	}
+}
+
+func TestCluster(t *testing.T) {
+	c := NewCluster("master@10.0.0.1:5050")
+
+	// tasks are listed by framework and ID along with their state and number
+	// of statuses, slaves by ID
+	type summary struct {
+		tasks       map[string][]string
+		unreachable map[string][]string
+		slaves      []string
+	}
+	summarize := func(sj State) summary {
+		s := summary{tasks: map[string][]string{}, unreachable: map[string][]string{}}
+		for _, f := range sj.Frameworks {
+			s.tasks[f.Name] = []string{}
+			for _, task := range f.Tasks {
+				s.tasks[f.Name] = append(s.tasks[f.Name], fmt.Sprintf("%s=%s/%d", task.ID, task.State, len(task.Statuses)))
+			}
+			for _, task := range f.UnreachableTasks {
+				s.unreachable[f.Name] = append(s.unreachable[f.Name], task.ID)
+			}
+		}
+		for _, slave := range sj.Slaves {
+			s.slaves = append(s.slaves, slave.ID+"@"+slave.PID.Host)
+		}
+		return s
+	}
+
+	for i, tt := range []struct {
+		event   string
+		changed bool
+		err     bool
+		want    summary
+	}{
+		{
+			`{"type": "SUBSCRIBED", "subscribed": {"get_state": {
+				"get_tasks": {
+					"tasks": [
+						{"task_id": {"value": "web.2"}, "framework_id": {"value": "F1"}, "state": "TASK_RUNNING"},
+						{"task_id": {"value": "web.1"}, "framework_id": {"value": "F1"}, "state": "TASK_STAGING"}
+					],
+					"unreachable_tasks": [{"task_id": {"value": "db.1"}, "framework_id": {"value": "F1"}, "state": "TASK_UNREACHABLE"}]
+				},
+				"get_frameworks": {"frameworks": [{"framework_info": {"id": {"value": "F1"}, "name": "marathon"}}]},
+				"get_agents": {"agents": [{"agent_info": {"id": {"value": "S1"}}, "pid": "slave(1)@10.0.0.11:5051"}]}
+			}}}`,
+			true, false,
+			summary{
+				map[string][]string{"marathon": {"web.1=TASK_STAGING/0", "web.2=TASK_RUNNING/0"}},
+				map[string][]string{"marathon": {"db.1"}},
+				[]string{"S1@10.0.0.11"},
+			},
+		},
+		{
+			`{"type": "TASK_UPDATED", "task_updated": {"framework_id": {"value": "F1"}, "state": "TASK_RUNNING",
+				"status": {"task_id": {"value": "web.1"}, "state": "TASK_RUNNING", "timestamp": 1500000000}}}`,
+			true, false,
+			summary{
+				map[string][]string{"marathon": {"web.1=TASK_RUNNING/1", "web.2=TASK_RUNNING/0"}},
+				map[string][]string{"marathon": {"db.1"}},
+				[]string{"S1@10.0.0.11"},
+			},
+		},
+		// terminal tasks are dropped
+		{
+			`{"type": "TASK_UPDATED", "task_updated": {"framework_id": {"value": "F1"}, "state": "TASK_KILLED",
+				"status": {"task_id": {"value": "web.2"}, "state": "TASK_KILLED"}}}`,
+			true, false,
+			summary{
+				map[string][]string{"marathon": {"web.1=TASK_RUNNING/1"}},
+				map[string][]string{"marathon": {"db.1"}},
+				[]string{"S1@10.0.0.11"},
+			},
+		},
+		// updates of unknown tasks are ignored
+		{
+			`{"type": "TASK_UPDATED", "task_updated": {"framework_id": {"value": "F1"}, "state": "TASK_RUNNING",
+				"status": {"task_id": {"value": "web.9"}, "state": "TASK_RUNNING"}}}`,
+			false, false,
+			summary{
+				map[string][]string{"marathon": {"web.1=TASK_RUNNING/1"}},
+				map[string][]string{"marathon": {"db.1"}},
+				[]string{"S1@10.0.0.11"},
+			},
+		},
+		{
+			`{"type": "AGENT_ADDED", "agent_added": {"agent": {"agent_info": {"id": {"value": "S2"}, "hostname": "10.0.0.12", "port": 5051}}}}`,
+			true, false,
+			summary{
+				map[string][]string{"marathon": {"web.1=TASK_RUNNING/1"}},
+				map[string][]string{"marathon": {"db.1"}},
+				[]string{"S1@10.0.0.11", "S2@10.0.0.12"},
+			},
+		},
+		{
+			`{"type": "AGENT_REMOVED", "agent_removed": {"agent_id": {"value": "S1"}}}`,
+			true, false,
+			summary{
+				map[string][]string{"marathon": {"web.1=TASK_RUNNING/1"}},
+				map[string][]string{"marathon": {"db.1"}},
+				[]string{"S2@10.0.0.12"},
+			},
+		},
+		// tasks of frameworks which aren't added yet are kept
+		{
+			`{"type": "TASK_ADDED", "task_added": {"task": {"task_id": {"value": "job.1"}, "framework_id": {"value": "F2"}, "state": "TASK_STAGING"}}}`,
+			true, false,
+			summary{
+				map[string][]string{"marathon": {"web.1=TASK_RUNNING/1"}},
+				map[string][]string{"marathon": {"db.1"}},
+				[]string{"S2@10.0.0.12"},
+			},
+		},
+		{
+			`{"type": "FRAMEWORK_ADDED", "framework_added": {"framework": {"framework_info": {"id": {"value": "F2"}, "name": "chronos"}}}}`,
+			true, false,
+			summary{
+				map[string][]string{"marathon": {"web.1=TASK_RUNNING/1"}, "chronos": {"job.1=TASK_STAGING/0"}},
+				map[string][]string{"marathon": {"db.1"}},
+				[]string{"S2@10.0.0.12"},
+			},
+		},
+		{
+			`{"type": "FRAMEWORK_REMOVED", "framework_removed": {"framework_info": {"id": {"value": "F1"}}}}`,
+			true, false,
+			summary{
+				map[string][]string{"chronos": {"job.1=TASK_STAGING/0"}},
+				map[string][]string{},
+				[]string{"S2@10.0.0.12"},
+			},
+		},
+		{
+			`{"type": "HEARTBEAT"}`,
+			false, false,
+			summary{
+				map[string][]string{"chronos": {"job.1=TASK_STAGING/0"}},
+				map[string][]string{},
+				[]string{"S2@10.0.0.12"},
+			},
+		},
+		{
+			`{"type": "AGENT_ADDED"}`,
+			false, true,
+			summary{
+				map[string][]string{"chronos": {"job.1=TASK_STAGING/0"}},
+				map[string][]string{},
+				[]string{"S2@10.0.0.12"},
+			},
+		},
+	} {
+		e, err := ParseEvent([]byte(tt.event))
+		if err != nil {
+			t.Fatalf("test #%d: %v", i, err)
+		}
+		changed, err := c.Apply(e)
+		if changed != tt.changed || (err != nil) != tt.err {
+			t.Errorf("test #%d: got changed: %t, err: %v, want changed: %t, err: %t", i, changed, err, tt.changed, tt.err)
+		}
+		sj := c.State()
+		if sj.Leader != "master@10.0.0.1:5050" {
+			t.Errorf("test #%d: got leader %q", i, sj.Leader)
+		}
+		if got := summarize(sj); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: got %+v, want %+v", i, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"strconv"
+
+	"github.com/mesos/mesos-go/upid"
+)
+
+// ParseOperatorMaster parses the response to a GET_MASTER call of the v1 Mesos
+// operator API and returns the PID of the master in the format of the leader
+// of /state.json, e.g. "master@10.0.0.1:5050".
+func ParseOperatorMaster(data []byte) (string, error) {
+	var resp struct {
+		GetMaster *struct {
+			MasterInfo struct {
+				PID     string `json:"pid"`
+				Port    int    `json:"port"`
+				Address struct {
+					IP   string `json:"ip"`
+					Port int    `json:"port"`
+				} `json:"address"`
+			} `json:"master_info"`
+		} `json:"get_master"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return "", err
+	} else if resp.GetMaster == nil {
+		return "", errors.New("missing get_master in response")
+	}
+
+	mi := resp.GetMaster.MasterInfo
+	if mi.PID != "" {
+		return mi.PID, nil
+	}
+	port := mi.Address.Port
+	if port == 0 {
+		port = mi.Port
+	}
+	if mi.Address.IP == "" || port == 0 {
+		return "", errors.New("missing address of master")
+	}
+	return "master@" + net.JoinHostPort(mi.Address.IP, strconv.Itoa(port)), nil
+}
+
+// ParseOperatorState parses the response to a GET_STATE call of the v1 Mesos
+// operator API into a State with the given leader. Tasks are grouped by their
+// frameworks like in /state.json; tasks of unknown frameworks are dropped.
+func ParseOperatorState(data []byte, leader string) (State, error) {
+	var resp struct {
+		GetState *operatorState `json:"get_state"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return State{}, err
+	} else if resp.GetState == nil {
+		return State{}, errors.New("missing get_state in response")
+	}
+	return resp.GetState.state(leader)
+}
+
+// operatorState holds the state of a cluster as defined in the v1 Mesos
+// operator API.
+type operatorState struct {
+	GetTasks struct {
+		Tasks            []operatorTask `json:"tasks"`
+		UnreachableTasks []operatorTask `json:"unreachable_tasks"`
+	} `json:"get_tasks"`
+	GetFrameworks struct {
+		Frameworks []operatorFramework `json:"frameworks"`
+	} `json:"get_frameworks"`
+	GetAgents struct {
+		Agents []operatorAgent `json:"agents"`
+	} `json:"get_agents"`
+}
+
+// state returns the State of /state.json holding the same data.
+func (gs *operatorState) state(leader string) (State, error) {
+	sj := State{Leader: leader}
+	frameworks := make(map[string]*Framework, len(gs.GetFrameworks.Frameworks))
+	sj.Frameworks = make([]Framework, len(gs.GetFrameworks.Frameworks))
+	for i, f := range gs.GetFrameworks.Frameworks {
+		sj.Frameworks[i] = f.framework()
+		frameworks[sj.Frameworks[i].ID] = &sj.Frameworks[i]
+	}
+	for _, t := range gs.GetTasks.Tasks {
+		if f, ok := frameworks[t.FrameworkID.Value]; ok {
+			f.Tasks = append(f.Tasks, t.task())
+		}
+	}
+	for _, t := range gs.GetTasks.UnreachableTasks {
+		if f, ok := frameworks[t.FrameworkID.Value]; ok {
+			f.UnreachableTasks = append(f.UnreachableTasks, t.task())
+		}
+	}
+
+	for _, a := range gs.GetAgents.Agents {
+		slave, err := a.slave()
+		if err != nil {
+			return State{}, err
+		}
+		sj.Slaves = append(sj.Slaves, slave)
+	}
+	return sj, nil
+}
+
+// operatorFrameworkInfo holds the info of a framework as defined in the v1
+// Mesos operator API.
+type operatorFrameworkInfo struct {
+	ID       operatorID `json:"id"`
+	Name     string     `json:"name"`
+	Hostname string     `json:"hostname"`
+}
+
+// operatorFramework holds a framework as defined in the v1 Mesos operator API.
+type operatorFramework struct {
+	FrameworkInfo operatorFrameworkInfo `json:"framework_info"`
+}
+
+// framework returns the Framework of /state.json holding the same data,
+// without tasks.
+func (f operatorFramework) framework() Framework {
+	return Framework{
+		ID:       f.FrameworkInfo.ID.Value,
+		Name:     f.FrameworkInfo.Name,
+		Hostname: f.FrameworkInfo.Hostname,
+	}
+}
+
+// operatorAgent holds an agent as defined in the v1 Mesos operator API.
+type operatorAgent struct {
+	AgentInfo struct {
+		ID       operatorID `json:"id"`
+		Hostname string     `json:"hostname"`
+		Port     int        `json:"port"`
+	} `json:"agent_info"`
+	PID string `json:"pid"`
+}
+
+// slave returns the Slave of /state.json holding the same data. Agents
+// without a PID get one made of their hostname and port.
+func (a operatorAgent) slave() (Slave, error) {
+	slave := Slave{ID: a.AgentInfo.ID.Value, Hostname: a.AgentInfo.Hostname}
+	pid := a.PID
+	if pid == "" {
+		pid = "slave(1)@" + net.JoinHostPort(a.AgentInfo.Hostname, strconv.Itoa(a.AgentInfo.Port))
+	}
+	var err error
+	slave.PID.UPID, err = upid.Parse(pid)
+	return slave, err
+}
+
+// operatorID holds the ID of an object of the v1 Mesos operator API.
+type operatorID struct {
+	Value string `json:"value"`
+}
+
+// operatorLabels holds the labels of an object of the v1 Mesos operator API.
+type operatorLabels struct {
+	Labels []Label `json:"labels"`
+}
+
+// operatorStatus holds a task status as defined in the v1 Mesos operator API.
+type operatorStatus struct {
+	TaskID          operatorID      `json:"task_id"`
+	Timestamp       float64         `json:"timestamp"`
+	State           string          `json:"state"`
+	Healthy         *bool           `json:"healthy,omitempty"`
+	Labels          operatorLabels  `json:"labels"`
+	ContainerStatus ContainerStatus `json:"container_status"`
+}
+
+// operatorTask holds a task as defined in the v1 Mesos operator API.
+type operatorTask struct {
+	TaskID      operatorID       `json:"task_id"`
+	FrameworkID operatorID       `json:"framework_id"`
+	AgentID     operatorID       `json:"agent_id"`
+	Name        string           `json:"name"`
+	State       string           `json:"state"`
+	Statuses    []operatorStatus `json:"statuses"`
+	Labels      operatorLabels   `json:"labels"`
+	Resources   Resources        `json:"resources"`
+	Discovery   DiscoveryInfo    `json:"discovery"`
+	HealthCheck *HealthCheck     `json:"health_check,omitempty"`
+}
+
+// task returns the Task of /state.json holding the same data.
+func (t operatorTask) task() Task {
+	task := Task{
+		FrameworkID:   t.FrameworkID.Value,
+		ID:            t.TaskID.Value,
+		Name:          t.Name,
+		SlaveID:       t.AgentID.Value,
+		State:         t.State,
+		Labels:        t.Labels.Labels,
+		Resources:     t.Resources,
+		DiscoveryInfo: t.Discovery,
+		HealthCheck:   t.HealthCheck,
+		Statuses:      make([]Status, len(t.Statuses)),
+	}
+	for i, st := range t.Statuses {
+		task.Statuses[i] = st.status()
+	}
+	return task
+}
+
+// status returns the Status of /state.json holding the same data.
+func (st operatorStatus) status() Status {
+	return Status{
+		Timestamp:       st.Timestamp,
+		State:           st.State,
+		Healthy:         st.Healthy,
+		Labels:          st.Labels.Labels,
+		ContainerStatus: st.ContainerStatus,
+	}
+}
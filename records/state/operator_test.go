@@ -0,0 +1,132 @@
+package state_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mesos/mesos-go/upid"
+	. "github.com/mesosphere/mesos-dns/records/state"
+)
+
+func TestParseOperatorMaster(t *testing.T) {
+	for i, tt := range []struct {
+		data string
+		want string
+		err  bool
+	}{
+		{`{"type": "GET_MASTER", "get_master": {"master_info": {"pid": "master@10.0.0.1:5050", "port": 5050}}}`, "master@10.0.0.1:5050", false},
+		{`{"type": "GET_MASTER", "get_master": {"master_info": {"port": 5050, "address": {"ip": "10.0.0.1", "port": 5050}}}}`, "master@10.0.0.1:5050", false},
+		{`{"type": "GET_MASTER", "get_master": {"master_info": {"port": 5050, "address": {"ip": "fd01::1"}}}}`, "master@[fd01::1]:5050", false},
+		{`{"type": "GET_MASTER", "get_master": {"master_info": {"port": 5050}}}`, "", true},
+		{`{"type": "GET_STATE", "get_state": {}}`, "", true},
+		{`not json`, "", true},
+	} {
+		got, err := ParseOperatorMaster([]byte(tt.data))
+		if got != tt.want || (err != nil) != tt.err {
+			t.Errorf("test #%d: got %q, %v, want %q, err: %t", i, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestParseOperatorState(t *testing.T) {
+	data := `{
+		"type": "GET_STATE",
+		"get_state": {
+			"get_tasks": {
+				"tasks": [{
+					"name": "web",
+					"task_id": {"value": "web.1"},
+					"framework_id": {"value": "F1"},
+					"agent_id": {"value": "S1"},
+					"state": "TASK_RUNNING",
+					"resources": [{"name": "ports", "type": "RANGES", "ranges": {"range": [{"begin": 31000, "end": 31001}]}}],
+					"labels": {"labels": [{"key": "team", "value": "web"}]},
+					"discovery": {"visibility": "FRAMEWORK", "name": "www", "ports": {"ports": [{"number": 80, "name": "http", "protocol": "tcp"}]}},
+					"health_check": {"type": "HTTP", "http": {"port": 80}},
+					"statuses": [{
+						"state": "TASK_RUNNING",
+						"timestamp": 1500000000.5,
+						"healthy": true,
+						"labels": {"labels": [{"key": "Docker.NetworkSettings.IPAddress", "value": "172.17.0.2"}]},
+						"container_status": {"network_infos": [{"ip_addresses": [{"ip_address": "10.1.0.2"}]}]}
+					}]
+				}, {
+					"name": "orphan",
+					"task_id": {"value": "orphan.1"},
+					"framework_id": {"value": "F9"},
+					"state": "TASK_RUNNING"
+				}],
+				"unreachable_tasks": [{
+					"name": "db",
+					"task_id": {"value": "db.1"},
+					"framework_id": {"value": "F1"},
+					"agent_id": {"value": "S2"},
+					"state": "TASK_UNREACHABLE"
+				}]
+			},
+			"get_frameworks": {
+				"frameworks": [{"framework_info": {"id": {"value": "F1"}, "name": "marathon", "hostname": "marathon.example.com"}, "active": true}]
+			},
+			"get_agents": {
+				"agents": [
+					{"agent_info": {"id": {"value": "S1"}, "hostname": "agent-1.example.com", "port": 5051}, "pid": "slave(1)@10.0.0.11:5051"},
+					{"agent_info": {"id": {"value": "S2"}, "hostname": "10.0.0.12", "port": 5051}}
+				]
+			}
+		}
+	}`
+
+	sj, err := ParseOperatorState([]byte(data), "master@10.0.0.1:5050")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	healthy := true
+	web := Task{
+		FrameworkID: "F1",
+		ID:          "web.1",
+		Name:        "web",
+		SlaveID:     "S1",
+		State:       "TASK_RUNNING",
+		Labels:      []Label{{Key: "team", Value: "web"}},
+		Resources:   Resources{StructuredPorts: Ranges{{31000, 31001}}},
+		HealthCheck: &HealthCheck{Type: "HTTP"},
+		Statuses: []Status{{
+			Timestamp: 1500000000.5,
+			State:     "TASK_RUNNING",
+			Healthy:   &healthy,
+			Labels:    []Label{{Key: "Docker.NetworkSettings.IPAddress", Value: "172.17.0.2"}},
+			ContainerStatus: ContainerStatus{NetworkInfos: []NetworkInfo{{
+				IPAddresses: []IPAddress{{IPAddress: "10.1.0.2"}},
+			}}},
+		}},
+	}
+	web.DiscoveryInfo.Visibilty = "FRAMEWORK"
+	web.DiscoveryInfo.Name = "www"
+	web.DiscoveryInfo.Ports.DiscoveryPorts = DiscoveryPorts{{Protocol: "tcp", Number: 80, Name: "http"}}
+
+	want := State{
+		Leader: "master@10.0.0.1:5050",
+		Frameworks: []Framework{{
+			ID:               "F1",
+			Name:             "marathon",
+			Hostname:         "marathon.example.com",
+			Tasks:            []Task{web},
+			UnreachableTasks: []Task{{FrameworkID: "F1", ID: "db.1", Name: "db", SlaveID: "S2", State: "TASK_UNREACHABLE", Statuses: []Status{}}},
+		}},
+		Slaves: []Slave{
+			{ID: "S1", Hostname: "agent-1.example.com", PID: PID{&upid.UPID{ID: "slave(1)", Host: "10.0.0.11", Port: "5051"}}},
+			{ID: "S2", Hostname: "10.0.0.12", PID: PID{&upid.UPID{ID: "slave(1)", Host: "10.0.0.12", Port: "5051"}}},
+		},
+	}
+	if !reflect.DeepEqual(sj, want) {
+		t.Errorf("got %+v, want %+v", sj, want)
+	}
+	if got := sj.Frameworks[0].Tasks[0].IPs("netinfo"); len(got) != 1 || got[0].String() != "10.1.0.2" {
+		t.Errorf("got netinfo IPs %v, want [10.1.0.2]", got)
+	}
+
+	if _, err := ParseOperatorState([]byte(`{"type": "GET_MASTER"}`), ""); err == nil {
+		t.Error("expected error for missing get_state")
+	}
+}
@@ -2,43 +2,171 @@ package state
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
 	"net"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mesos/mesos-go/upid"
 )
 
 // Resources holds resources as defined in the /state.json Mesos HTTP endpoint.
+// Besides the object of scalar and text values of /state.json, it accepts the
+// arrays of structured resources newer Mesos versions report.
 type Resources struct {
 	PortRanges string `json:"ports"`
+
+	// StructuredPorts holds the port ranges of structured resources
+	StructuredPorts Ranges `json:"-"`
 }
 
-// Ports returns a slice of individual ports expanded from PortRanges.
-func (r Resources) Ports() []string {
-	if r.PortRanges == "" || r.PortRanges == "[]" {
-		return []string{}
+// UnmarshalJSON implements the json.Unmarshaler interface for Resources.
+func (r *Resources) UnmarshalJSON(data []byte) error {
+	if data = bytes.TrimSpace(data); len(data) == 0 || data[0] != '[' {
+		type plain Resources
+		return json.Unmarshal(data, (*plain)(r))
 	}
 
-	rhs := strings.Split(r.PortRanges, "[")[1]
-	lhs := strings.Split(rhs, "]")[0]
+	var rs []struct {
+		Name   string `json:"name"`
+		Ranges struct {
+			Range Ranges `json:"range"`
+		} `json:"ranges"`
+	}
+	if err := json.Unmarshal(data, &rs); err != nil {
+		return err
+	}
+	*r = Resources{}
+	for _, res := range rs {
+		if res.Name == "ports" {
+			r.StructuredPorts = append(r.StructuredPorts, res.Ranges.Range...)
+		}
+	}
+	return nil
+}
 
-	yports := []string{}
+// Ports returns the individual ports of PortRanges and StructuredPorts,
+// sorted and without duplicates. Malformed ranges are skipped and reported by
+// the returned error, along with the ports of the well-formed ones.
+func (r Resources) Ports() ([]string, error) {
+	rs, err := ParseRanges(r.PortRanges)
+	valid := make(Ranges, 0, len(rs)+len(r.StructuredPorts))
+	for _, rg := range append(rs, r.StructuredPorts...) {
+		if rg.Begin > rg.End || rg.End > math.MaxUint16 {
+			if err == nil {
+				err = fmt.Errorf("invalid port range %d-%d", rg.Begin, rg.End)
+			}
+			continue
+		}
+		valid = append(valid, rg)
+	}
 
-	mports := strings.Split(lhs, ",")
-	for _, port := range mports {
-		tmp := strings.TrimSpace(port)
-		pz := strings.Split(tmp, "-")
-		lo, _ := strconv.Atoi(pz[0])
-		hi, _ := strconv.Atoi(pz[1])
+	var ports []string
+	for _, rg := range valid.Squash() {
+		for p := rg.Begin; p <= rg.End; p++ {
+			ports = append(ports, strconv.FormatUint(p, 10))
+		}
+	}
+	return ports, err
+}
+
+// Range is an inclusive range of values as defined in the resources of the
+// /state.json Mesos HTTP endpoint.
+type Range struct {
+	Begin uint64 `json:"begin"`
+	End   uint64 `json:"end"`
+}
+
+// Ranges is a list of Range.
+type Ranges []Range
+
+// ParseRanges parses ranges in the text format of the /state.json Mesos HTTP
+// endpoint, e.g. "[31000-31005, 31010-31010]". Single values, e.g. "[31000]",
+// are ranges of one value. Malformed ranges are skipped and reported by the
+// returned error, along with the well-formed ones.
+func ParseRanges(s string) (Ranges, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	if len(s) < 2 || s[0] != '[' || s[len(s)-1] != ']' {
+		return nil, fmt.Errorf("malformed ranges %q: missing brackets", s)
+	}
+
+	inner := strings.TrimSpace(s[1 : len(s)-1])
+	if inner == "" {
+		return nil, nil
+	}
+
+	var (
+		rs  Ranges
+		err error
+	)
+	for _, field := range strings.Split(inner, ",") {
+		rg, e := parseRange(strings.TrimSpace(field))
+		if e != nil {
+			if err == nil {
+				err = e
+			}
+			continue
+		}
+		rs = append(rs, rg)
+	}
+	return rs, err
+}
+
+// parseRange parses a single range, e.g. "31000-31005" or "31000".
+func parseRange(s string) (Range, error) {
+	lo, hi := s, s
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		lo, hi = s[:i], s[i+1:]
+	}
+	begin, err := strconv.ParseUint(strings.TrimSpace(lo), 10, 64)
+	if err != nil {
+		return Range{}, fmt.Errorf("malformed range %q", s)
+	}
+	end, err := strconv.ParseUint(strings.TrimSpace(hi), 10, 64)
+	if err != nil {
+		return Range{}, fmt.Errorf("malformed range %q", s)
+	}
+	if begin > end {
+		return Range{}, fmt.Errorf("malformed range %q: begin exceeds end", s)
+	}
+	return Range{begin, end}, nil
+}
 
-		for t := lo; t <= hi; t++ {
-			yports = append(yports, strconv.Itoa(t))
+// Squash returns the ranges sorted, with overlapping and adjacent ranges
+// merged.
+func (rs Ranges) Squash() Ranges {
+	if len(rs) == 0 {
+		return nil
+	}
+	sorted := append(Ranges(nil), rs...)
+	sort.Sort(byBegin(sorted))
+
+	squashed := sorted[:1]
+	for _, rg := range sorted[1:] {
+		last := &squashed[len(squashed)-1]
+		if rg.Begin > last.End && rg.Begin-last.End > 1 {
+			squashed = append(squashed, rg)
+		} else if rg.End > last.End {
+			last.End = rg.End
 		}
 	}
-	return yports
+	return squashed
 }
 
+// byBegin sorts ranges by ascending begin.
+type byBegin Ranges
+
+func (rs byBegin) Len() int           { return len(rs) }
+func (rs byBegin) Swap(i, j int)      { rs[i], rs[j] = rs[j], rs[i] }
+func (rs byBegin) Less(i, j int) bool { return rs[i].Begin < rs[j].Begin }
+
 // Label holds a label as defined in the /state.json Mesos HTTP endpoint.
 type Label struct {
 	Key   string `json:"key"`
@@ -49,6 +177,7 @@ type Label struct {
 type Status struct {
 	Timestamp       float64         `json:"timestamp"`
 	State           string          `json:"state"`
+	Healthy         *bool           `json:"healthy,omitempty"`
 	Labels          []Label         `json:"labels,omitempty"`
 	ContainerStatus ContainerStatus `json:"container_status,omitempty"`
 }
@@ -62,21 +191,38 @@ type ContainerStatus struct {
 // NetworkInfo holds a network address resolution as defined in the
 // /state.json Mesos HTTP endpoint.
 type NetworkInfo struct {
+	IPAddress   string      `json:"ip_address,omitempty"`
+	IPAddresses []IPAddress `json:"ip_addresses,omitempty"`
+}
+
+// IPAddress holds a single IP address of a NetworkInfo as defined in the
+// /state.json Mesos HTTP endpoint. Dual-stack containers report one of these
+// for each address family.
+type IPAddress struct {
+	Protocol  string `json:"protocol,omitempty"`
 	IPAddress string `json:"ip_address,omitempty"`
 }
 
 // Task holds a task as defined in the /state.json Mesos HTTP endpoint.
 type Task struct {
-	FrameworkID   string   `json:"framework_id"`
-	ID            string   `json:"id"`
-	Name          string   `json:"name"`
-	SlaveID       string   `json:"slave_id"`
-	State         string   `json:"state"`
-	Statuses      []Status `json:"statuses"`
-	Resources     `json:"resources"`
+	FrameworkID   string        `json:"framework_id"`
+	ID            string        `json:"id"`
+	Name          string        `json:"name"`
+	SlaveID       string        `json:"slave_id"`
+	State         string        `json:"state"`
+	Statuses      []Status      `json:"statuses"`
+	Labels        []Label       `json:"labels,omitempty"`
+	Resources     Resources     `json:"resources"`
 	DiscoveryInfo DiscoveryInfo `json:"discovery"`
+	HealthCheck   *HealthCheck  `json:"health_check,omitempty"`
 
-	SlaveIP string `json:"-"`
+	SlaveIPs []string `json:"-"`
+}
+
+// HealthCheck holds the health check of a task as defined in the /state.json
+// Mesos HTTP endpoint, which only tells whether the task has one.
+type HealthCheck struct {
+	Type string `json:"type,omitempty"`
 }
 
 // HasDiscoveryInfo return whether the DiscoveryInfo was provided in the state.json
@@ -120,15 +266,21 @@ var sources = map[string]func(*Task) []string{
 
 // hostIPs is an IPSource which returns the IP addresses of the slave a Task
 // runs on.
-func hostIPs(t *Task) []string { return []string{t.SlaveIP} }
+func hostIPs(t *Task) []string { return t.SlaveIPs }
 
 // networkInfoIPs returns IP addresses from a given Task's
-// []Status.ContainerStatus.[]NetworkInfos.IPAddress
+// []Status.ContainerStatus.[]NetworkInfos.IPAddress and
+// []Status.ContainerStatus.[]NetworkInfos.[]IPAddresses.IPAddress
 func networkInfoIPs(t *Task) []string {
 	return statusIPs(t.Statuses, func(s *Status) []string {
-		ips := make([]string, len(s.ContainerStatus.NetworkInfos))
-		for i, netinfo := range s.ContainerStatus.NetworkInfos {
-			ips[i] = netinfo.IPAddress
+		ips := make([]string, 0, len(s.ContainerStatus.NetworkInfos))
+		for _, netinfo := range s.ContainerStatus.NetworkInfos {
+			if netinfo.IPAddress != "" {
+				ips = append(ips, netinfo.IPAddress)
+			}
+			for _, addr := range netinfo.IPAddresses {
+				ips = append(ips, addr.IPAddress)
+			}
 		}
 		return ips
 	})
@@ -154,20 +306,63 @@ func mesosIPs(t *Task) []string {
 	return statusIPs(t.Statuses, labels(MesosIPLabel))
 }
 
+// Healthy returns whether a Task passes its health checks as reported in its
+// latest running status. Tasks without health checks are considered healthy,
+// tasks with health checks which didn't report a result yet unhealthy.
+func (t *Task) Healthy() bool {
+	if s := latestRunning(t.Statuses); s != nil && s.Healthy != nil {
+		return *s.Healthy
+	}
+	return t.HealthCheck == nil
+}
+
+// StatusLabels returns the labels of the latest running status of a Task.
+func (t *Task) StatusLabels() []Label {
+	if s := latestRunning(t.Statuses); s != nil {
+		return s.Labels
+	}
+	return nil
+}
+
 // statusIPs returns the latest running status IPs extracted with the given src
-func statusIPs(st []Status, src func(*Status) []string) (ips []string) {
+func statusIPs(st []Status, src func(*Status) []string) []string {
+	if s := latestRunning(st); s != nil {
+		return src(s)
+	}
+	return nil
+}
+
+// Entered returns the time of the latest status of a Task in the given state
+// and whether there's one.
+func (t *Task) Entered(state string) (time.Time, bool) {
+	s := latestStatus(t.Statuses, state)
+	if s == nil {
+		return time.Time{}, false
+	}
+	sec, frac := math.Modf(s.Timestamp)
+	return time.Unix(int64(sec), int64(frac*1e9)), true
+}
+
+// latestRunning returns the latest running status or nil if there's none.
+func latestRunning(st []Status) *Status {
+	return latestStatus(st, "TASK_RUNNING")
+}
+
+// latestStatus returns the latest status in the given state or nil if
+// there's none.
+func latestStatus(st []Status, state string) (latest *Status) {
 	// the state.json we extract from mesos makes no guarantees re: the order
 	// of the task statuses so we should check the timestamps to avoid problems
 	// down the line. we can't rely on seeing the same sequence. (@joris)
 	// https://github.com/apache/mesos/blob/0.24.0/src/slave/slave.cpp#L5226-L5238
 	lastTimestamp := float64(-1.0)
 	for i := range st {
-		if st[i].State == "TASK_RUNNING" && st[i].Timestamp > lastTimestamp {
+		if st[i].State == state && st[i].Timestamp > lastTimestamp {
 			lastTimestamp = st[i].Timestamp
-			ips = src(&st[i])
+			latest = &st[i]
 		}
 	}
-	return
+	return latest
 }
 
 // labels returns all given Status.[]Labels' values whose keys are equal
@@ -186,10 +381,12 @@ func labels(key string) func(*Status) []string {
 
 // Framework holds a framework as defined in the /state.json Mesos HTTP endpoint.
 type Framework struct {
-	Tasks    []Task `json:"tasks"`
-	PID      PID    `json:"pid"`
-	Name     string `json:"name"`
-	Hostname string `json:"hostname"`
+	ID               string `json:"id"`
+	Tasks            []Task `json:"tasks"`
+	UnreachableTasks []Task `json:"unreachable_tasks,omitempty"`
+	PID              PID    `json:"pid"`
+	Name             string `json:"name"`
+	Hostname         string `json:"hostname"`
 }
 
 // HostPort returns the hostname and port where a framework's scheduler is
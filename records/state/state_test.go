@@ -5,16 +5,75 @@ import (
 	"net"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/mesos/mesos-go/upid"
 	. "github.com/mesosphere/mesos-dns/records/state"
 )
 
 func TestResources_Ports(t *testing.T) {
-	r := Resources{PortRanges: "[31111-31111, 31115-31117]"}
-	want := []string{"31111", "31115", "31116", "31117"}
-	if got := r.Ports(); !reflect.DeepEqual(got, want) {
-		t.Fatalf("got: %v, want: %v", got, want)
+	for i, tt := range []struct {
+		r    Resources
+		want []string
+		err  bool
+	}{
+		{Resources{}, nil, false},
+		{Resources{PortRanges: "[]"}, nil, false},
+		{Resources{PortRanges: "[31111-31111, 31115-31117]"}, []string{"31111", "31115", "31116", "31117"}, false},
+		{Resources{PortRanges: "[31000]"}, []string{"31000"}, false},
+		{Resources{PortRanges: " [ 31000 - 31001 ,31005] "}, []string{"31000", "31001", "31005"}, false},
+		// overlapping and duplicate ranges
+		{Resources{PortRanges: "[31003-31004, 31000-31003, 31004-31004]"}, []string{"31000", "31001", "31002", "31003", "31004"}, false},
+		// malformed ranges are skipped
+		{Resources{PortRanges: "31000-31001"}, nil, true},
+		{Resources{PortRanges: "[31000-31001"}, nil, true},
+		{Resources{PortRanges: "[31000-, 31005]"}, []string{"31005"}, true},
+		{Resources{PortRanges: "[-31000, 31005]"}, []string{"31005"}, true},
+		{Resources{PortRanges: "[31001-31000, 31005]"}, []string{"31005"}, true},
+		{Resources{PortRanges: "[http, 31005]"}, []string{"31005"}, true},
+		{Resources{PortRanges: "[31000,,31005]"}, []string{"31000", "31005"}, true},
+		{Resources{PortRanges: "[65535-65536]"}, nil, true},
+		// structured resources
+		{Resources{StructuredPorts: Ranges{{31002, 31003}, {31000, 31001}}}, []string{"31000", "31001", "31002", "31003"}, false},
+		{Resources{StructuredPorts: Ranges{{31001, 31000}, {31005, 31005}}}, []string{"31005"}, true},
+		{Resources{PortRanges: "[31000-31001]", StructuredPorts: Ranges{{31001, 31002}}}, []string{"31000", "31001", "31002"}, false},
+	} {
+		got, err := tt.r.Ports()
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: got: %v, want: %v", i, got, tt.want)
+		}
+		if (err != nil) != tt.err {
+			t.Errorf("test #%d: got err: %v, want err: %t", i, err, tt.err)
+		}
+	}
+}
+
+func TestResources_UnmarshalJSON(t *testing.T) {
+	for i, tt := range []struct {
+		data string
+		want Resources
+	}{
+		{`{"cpus": 0.1, "mem": 64, "ports": "[31000-31001]"}`, Resources{PortRanges: "[31000-31001]"}},
+		{`{"cpus": 0.1, "mem": 64}`, Resources{}},
+		{`null`, Resources{}},
+		{`[
+			{"name": "cpus", "type": "SCALAR", "scalar": {"value": 0.1}},
+			{"name": "ports", "type": "RANGES", "ranges": {"range": [{"begin": 31000, "end": 31001}]}, "role": "*"},
+			{"name": "ports", "type": "RANGES", "ranges": {"range": [{"begin": 31005, "end": 31005}]}, "role": "web"}
+		]`, Resources{StructuredPorts: Ranges{{31000, 31001}, {31005, 31005}}}},
+		{`[]`, Resources{}},
+	} {
+		var task Task
+		if err := json.Unmarshal([]byte(`{"id": "web.1", "resources": `+tt.data+`}`), &task); err != nil {
+			t.Errorf("test #%d: unexpected error: %v", i, err)
+			continue
+		}
+		if task.ID != "web.1" {
+			t.Errorf("test #%d: got task ID %q", i, task.ID)
+		}
+		if !reflect.DeepEqual(task.Resources, tt.want) {
+			t.Errorf("test #%d: got: %+v, want: %+v", i, task.Resources, tt.want)
+		}
 	}
 }
 
@@ -62,7 +121,7 @@ func TestTask_IPs(t *testing.T) {
 		},
 		{ // source order
 			Task: task(
-				slaveIP("2.3.4.5"),
+				slaveIPs("2.3.4.5"),
 				statuses(status(state("TASK_RUNNING"), netinfo("1.2.3.4"))),
 			),
 			srcs: []string{"host", "netinfo"},
@@ -91,6 +150,18 @@ func TestTask_IPs(t *testing.T) {
 			srcs: []string{"docker", "netinfo"},
 			want: ips("2.4.6.8"),
 		},
+		{ // dual-stack network infos
+			Task: task(
+				statuses(
+					status(
+						state("TASK_RUNNING"),
+						netinfoAddrs("1.2.3.4", "2001:db8::1"),
+					),
+				),
+			),
+			srcs: []string{"netinfo"},
+			want: ips("1.2.3.4", "2001:db8::1"),
+		},
 		{ // label ordering
 			Task: task(
 				statuses(
@@ -111,6 +182,71 @@ func TestTask_IPs(t *testing.T) {
 	}
 }
 
+func TestTask_Healthy(t *testing.T) {
+	for i, tt := range []struct {
+		*Task
+		want bool
+	}{
+		{task(), true},
+		{task(statuses(status(state("TASK_RUNNING")))), true},
+		{task(statuses(status(state("TASK_RUNNING"), healthy(true)))), true},
+		{task(statuses(status(state("TASK_RUNNING"), healthy(false)))), false},
+		// health checks without results yet
+		{task(healthCheck(), statuses(status(state("TASK_RUNNING")))), false},
+		{task(healthCheck(), statuses(status(state("TASK_RUNNING"), healthy(true)))), true},
+		{ // only running statuses count
+			Task: task(
+				statuses(
+					status(state("TASK_RUNNING"), healthy(true), timestamp(1)),
+					status(state("TASK_KILLED"), healthy(false), timestamp(2)),
+				),
+			),
+			want: true,
+		},
+		{ // latest status wins
+			Task: task(
+				statuses(
+					status(state("TASK_RUNNING"), healthy(false), timestamp(3)),
+					status(state("TASK_RUNNING"), healthy(true), timestamp(2)),
+				),
+			),
+			want: false,
+		},
+	} {
+		if got := tt.Healthy(); got != tt.want {
+			t.Errorf("test #%d: got %t, want %t", i, got, tt.want)
+		}
+	}
+}
+
+func TestTask_Entered(t *testing.T) {
+	for i, tt := range []struct {
+		*Task
+		state string
+		want  time.Time
+		ok    bool
+	}{
+		{task(), "TASK_UNREACHABLE", time.Time{}, false},
+		{task(statuses(status(state("TASK_RUNNING"), timestamp(1)))), "TASK_UNREACHABLE", time.Time{}, false},
+		{ // latest status in the given state wins
+			Task: task(
+				statuses(
+					status(state("TASK_UNREACHABLE"), timestamp(2)),
+					status(state("TASK_UNREACHABLE"), timestamp(4.5)),
+					status(state("TASK_RUNNING"), timestamp(5)),
+				),
+			),
+			state: "TASK_UNREACHABLE",
+			want:  time.Unix(4, 5e8),
+			ok:    true,
+		},
+	} {
+		if got, ok := tt.Entered(tt.state); !got.Equal(tt.want) || ok != tt.ok {
+			t.Errorf("test #%d: got (%v, %t), want (%v, %t)", i, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
 // test helpers
 
 type (
@@ -140,8 +276,12 @@ func statuses(st ...Status) taskOpt {
 	}
 }
 
-func slaveIP(ip string) taskOpt {
-	return func(t *Task) { t.SlaveIP = ip }
+func healthCheck() taskOpt {
+	return func(t *Task) { t.HealthCheck = &HealthCheck{Type: "HTTP"} }
+}
+
+func slaveIPs(ips ...string) taskOpt {
+	return func(t *Task) { t.SlaveIPs = ips }
 }
 
 func status(opts ...statusOpt) Status {
@@ -176,6 +316,20 @@ func netinfo(ips ...string) statusOpt {
 	}
 }
 
+func netinfoAddrs(ips ...string) statusOpt {
+	return func(s *Status) {
+		var netinfo NetworkInfo
+		for _, ip := range ips {
+			netinfo.IPAddresses = append(netinfo.IPAddresses, IPAddress{IPAddress: ip})
+		}
+		s.ContainerStatus.NetworkInfos = append(s.ContainerStatus.NetworkInfos, netinfo)
+	}
+}
+
+func healthy(h bool) statusOpt {
+	return func(s *Status) { s.Healthy = &h }
+}
+
 func timestamp(t float64) statusOpt {
 	return func(s *Status) { s.Timestamp = t }
 }
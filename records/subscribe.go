@@ -0,0 +1,185 @@
+package records
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mesosphere/mesos-dns/logging"
+	"github.com/mesosphere/mesos-dns/records/state"
+)
+
+const (
+	// defaultHeartbeatInterval is the interval of the HEARTBEAT events of
+	// subscriptions until their SUBSCRIBED event announces it.
+	defaultHeartbeatInterval = 15 * time.Second
+
+	// missedHeartbeats is the number of HEARTBEAT events a subscription may
+	// miss before it's considered broken.
+	missedHeartbeats = 3
+
+	// maxRecordLength is the maximum length of a record of the event stream.
+	maxRecordLength = 1 << 30
+)
+
+// errMissedHeartbeats is the error of subscriptions which stopped receiving
+// events.
+var errMissedHeartbeats = errors.New("missed heartbeats")
+
+// Subscription is a subscription to the events of the SUBSCRIBE call of the
+// v1 operator API of the leading Mesos master.
+type Subscription struct {
+	// Leader is the PID of the leading master, in the format of the leader of
+	// /state.json
+	Leader string
+
+	// Events delivers the events of the subscription, starting with the
+	// SUBSCRIBED event holding the full state, without HEARTBEAT events. It's
+	// closed once the subscription ends; Err returns why.
+	Events <-chan state.Event
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	err    error
+}
+
+// Subscribe subscribes to the events of the leading master, found through the
+// first of the given masters which answers. Non-leading masters redirect to
+// the leader. The requests are sent with the scheme, TLS settings and
+// credentials of the given config.
+func Subscribe(c Config, masters ...string) (*Subscription, error) {
+	for _, master := range masters {
+		if master == "" {
+			continue
+		}
+		ip, port, err := getProto(master)
+		if err != nil {
+			logging.Error.Println(err)
+			continue
+		}
+		sub, err := subscribe(c.masterClient(), net.JoinHostPort(ip, port))
+		if err == nil {
+			return sub, nil
+		}
+		logging.VeryVerbose.Printf("cannot subscribe to master %s: %v", master, err)
+	}
+	return nil, errors.New("no master")
+}
+
+// subscribe subscribes to the events of the leader of the master at the given
+// address.
+func subscribe(mc *masterClient, addr string) (*Subscription, error) {
+	body, leaderAddr, err := mc.operatorCall(addr, "GET_MASTER")
+	if err != nil {
+		return nil, err
+	}
+	leader, err := state.ParseOperatorMaster(body)
+	if err != nil {
+		return nil, err
+	}
+	mc.trustLeader(leader)
+
+	req, err := mc.operatorRequest(leaderAddr, "SUBSCRIBE")
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	resp, err := mc.do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	} else if resp.StatusCode != http.StatusOK {
+		err = operatorError("SUBSCRIBE", leaderAddr, resp)
+		_ = resp.Body.Close()
+		cancel()
+		return nil, err
+	}
+
+	events := make(chan state.Event)
+	sub := &Subscription{Leader: leader, Events: events, ctx: ctx, cancel: cancel}
+	go sub.read(resp.Body, events)
+	return sub, nil
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.cancel()
+}
+
+// Err returns why the subscription ended, once Events is closed.
+func (s *Subscription) Err() error {
+	return s.err
+}
+
+// read delivers the events of the given stream until it ends or misses
+// heartbeats, and closes events.
+func (s *Subscription) read(body io.ReadCloser, events chan<- state.Event) {
+	defer close(events)
+	defer func() { _ = body.Close() }()
+
+	missed := make(chan struct{})
+	timeout := missedHeartbeats * defaultHeartbeatInterval
+	watchdog := time.AfterFunc(timeout, func() {
+		close(missed)
+		s.cancel()
+	})
+	defer watchdog.Stop()
+
+	r := bufio.NewReader(body)
+	var err error
+	for err == nil {
+		var data []byte
+		if data, err = readRecord(r); err != nil || !watchdog.Stop() {
+			break
+		}
+
+		var e state.Event
+		if e, err = state.ParseEvent(data); err != nil {
+			break
+		}
+		if e.HeartbeatInterval > 0 {
+			timeout = missedHeartbeats * e.HeartbeatInterval
+		}
+		if e.Type != "HEARTBEAT" {
+			select {
+			case events <- e:
+			case <-s.ctx.Done():
+				err = s.ctx.Err()
+			}
+		}
+		watchdog.Reset(timeout)
+	}
+
+	select {
+	case <-missed:
+		s.err = errMissedHeartbeats
+	default:
+		s.err = err
+	}
+}
+
+// readRecord reads a record of the RecordIO format of the event stream: its
+// length in decimal, a newline and its data.
+func readRecord(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.ParseUint(strings.TrimSpace(line), 10, 64)
+	if err != nil || n > maxRecordLength {
+		return nil, fmt.Errorf("malformed record length %q", strings.TrimSpace(line))
+	}
+	data := make([]byte, n)
+	if _, err = io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,97 @@
+package records
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mesosphere/mesos-dns/mesostest"
+	"github.com/mesosphere/mesos-dns/records/state"
+)
+
+func TestReadRecord(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("5\nhello2\nhi0\n1\n\n3\nab"))
+	var got []string
+	var err error
+	for err == nil {
+		var data []byte
+		if data, err = readRecord(r); err == nil {
+			got = append(got, string(data))
+		}
+	}
+	if want := []string{"hello", "hi", "", "\n"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("got err: %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+
+	if _, err = readRecord(bufio.NewReader(strings.NewReader("x\n"))); err == nil {
+		t.Error("expected error for malformed length")
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	m := mesostest.NewMaster(`{"get_frameworks": {"frameworks": [{"framework_info": {"id": {"value": "F1"}, "name": "marathon"}}]}}`)
+	defer m.Close()
+
+	if _, err := Subscribe(NewConfig(), "", "127.0.0.1:1"); err == nil {
+		t.Fatal("expected error without masters")
+	}
+
+	sub, err := Subscribe(NewConfig(), "", "127.0.0.1:1", m.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "master@" + m.Addr; sub.Leader != want {
+		t.Errorf("got leader %q, want %q", sub.Leader, want)
+	}
+
+	recv := func() (state.Event, bool) {
+		select {
+		case e, ok := <-sub.Events:
+			return e, ok
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for events")
+			return state.Event{}, false
+		}
+	}
+
+	if e, _ := recv(); e.Type != "SUBSCRIBED" || e.HeartbeatInterval != 15*time.Second {
+		t.Errorf("got %q every %v, want SUBSCRIBED every 15s", e.Type, e.HeartbeatInterval)
+	}
+
+	// heartbeats aren't delivered
+	<-m.Subscribed()
+	m.Send(`{"type": "HEARTBEAT"}`)
+	m.Send(`{"type": "AGENT_REMOVED", "agent_removed": {"agent_id": {"value": "S1"}}}`)
+	if e, _ := recv(); e.Type != "AGENT_REMOVED" {
+		t.Errorf("got %q, want AGENT_REMOVED", e.Type)
+	}
+
+	m.Disconnect()
+	if _, ok := recv(); ok {
+		t.Fatal("expected closed channel after disconnecting")
+	}
+	if sub.Err() != io.EOF {
+		t.Errorf("got err: %v, want %v", sub.Err(), io.EOF)
+	}
+
+	// subscriptions missing heartbeats end
+	m.SetHeartbeatInterval(10 * time.Millisecond)
+	if sub, err = Subscribe(NewConfig(), m.Addr); err != nil {
+		t.Fatal(err)
+	}
+	if e, _ := recv(); e.Type != "SUBSCRIBED" {
+		t.Errorf("got %q, want SUBSCRIBED", e.Type)
+	}
+	if _, ok := recv(); ok {
+		t.Fatal("expected closed channel after missing heartbeats")
+	}
+	if sub.Err() != errMissedHeartbeats {
+		t.Errorf("got err: %v, want %v", sub.Err(), errMissedHeartbeats)
+	}
+}
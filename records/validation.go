@@ -2,10 +2,13 @@ package records
 
 import (
 	"fmt"
-	"github.com/miekg/dns"
 	"net"
 	"os"
 	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
 )
 
 // ValidHostPortRegex can validate Host:Port pairs (though it allow anychar as host for now and ports > 65365 < 99999)
@@ -81,31 +84,343 @@ func validateStaticEntryFile(sef string) (StaticEntryConfig, error) {
 
 	conf, err := ParseStaticConfig(sef)
 
-	for _, entry := range conf.Entries {
-		switch entry.Type {
-		case "A":
-			ip := net.ParseIP(entry.Value)
-			if ip == nil {
-				return conf, fmt.Errorf("Invalid IP on StaticEntry: %q", entry.Value)
-			}
-			if !dns.IsFqdn(entry.Fqdn) {
-				return conf, fmt.Errorf("Invalid FQDN: %s", entry.Fqdn)
-			}
-			break
-		case "SRV":
-			if _, ok := dns.IsDomainName(entry.Fqdn); !ok {
-				return conf, fmt.Errorf("Invalid SRV FQDN: %s", entry.Fqdn)
+	if err := validateStaticEntries(conf.Entries); err != nil {
+		return conf, err
+	}
+
+	return conf, err
+}
+
+// validateStaticEntries checks that the given StaticEntries are valid and
+// that no name has more than one CNAME entry.
+func validateStaticEntries(entries []StaticEntry) error {
+	cnames := map[string]bool{}
+	for _, entry := range entries {
+		if err := validateStaticEntry(entry); err != nil {
+			return err
+		}
+		if entry.Type != "CNAME" {
+			continue
+		}
+		name := strings.ToLower(entry.Fqdn)
+		if cnames[name] {
+			return fmt.Errorf("Duplicate CNAME StaticEntry: %s", entry.Fqdn)
+		}
+		cnames[name] = true
+	}
+	return nil
+}
+
+// validateStaticEntry checks that the name and value of the given StaticEntry
+// are valid for its record type.
+func validateStaticEntry(entry StaticEntry) error {
+	switch entry.Type {
+	case "A":
+		ip := net.ParseIP(entry.Value)
+		if ip == nil || ip.To4() == nil {
+			return fmt.Errorf("Invalid IP on StaticEntry: %q", entry.Value)
+		}
+		return validateFqdn(entry.Fqdn)
+	case "AAAA":
+		ip := net.ParseIP(entry.Value)
+		if ip == nil || ip.To4() != nil {
+			return fmt.Errorf("Invalid IPv6 on StaticEntry: %q", entry.Value)
+		}
+		return validateFqdn(entry.Fqdn)
+	case "SRV":
+		if _, ok := dns.IsDomainName(entry.Fqdn); !ok {
+			return fmt.Errorf("Invalid SRV FQDN: %s", entry.Fqdn)
+		}
+		if err := validateWildcard(entry.Fqdn); err != nil {
+			return err
+		}
+		if match, _ := regexp.MatchString(ValidHostPortRegex, entry.Value); !match {
+			return fmt.Errorf("Invalid (Host:Port) tuple: %s", entry.Value)
+		}
+		return nil
+	case "CNAME":
+		if err := validateFqdn(entry.Fqdn); err != nil {
+			return err
+		}
+		return validateTarget(entry.Value)
+	case "NS", "PTR":
+		if strings.Contains(entry.Fqdn, "*") {
+			return fmt.Errorf("Wildcard %s StaticEntry not supported: %s", entry.Type, entry.Fqdn)
+		}
+		if entry.Type == "NS" || net.ParseIP(entry.Fqdn) == nil {
+			if err := validateFqdn(entry.Fqdn); err != nil {
+				return err
 			}
-			if match, _ := regexp.MatchString(ValidHostPortRegex, entry.Value); !match {
-				return conf, fmt.Errorf("Invalid (Host:Port) tuple: %s", entry.Value)
+		}
+		return validateTarget(entry.Value)
+	case "TXT":
+		if entry.Value == "" {
+			return fmt.Errorf("Empty TXT value for %s", entry.Fqdn)
+		}
+		return validateFqdn(entry.Fqdn)
+	case "MX":
+		if err := validateFqdn(entry.Fqdn); err != nil {
+			return err
+		}
+		_, host, err := SplitMX(entry.Value)
+		if err != nil {
+			return err
+		}
+		return validateTarget(host)
+	default:
+		return fmt.Errorf("Unsupported Record Type: %s", entry.Type)
+	}
+}
+
+// validateFqdn checks that the given name is a fully qualified domain name,
+// optionally a wildcard one.
+func validateFqdn(name string) error {
+	if _, ok := dns.IsDomainName(name); !ok || !dns.IsFqdn(name) {
+		return fmt.Errorf("Invalid FQDN: %s", name)
+	}
+	return validateWildcard(name)
+}
+
+// validateWildcard checks that the given name is either no wildcard name or
+// one as per RFC 4592, i.e. a "*" leftmost label below at least one other
+// label, e.g. "*.apps.mesos.".
+func validateWildcard(name string) error {
+	i := strings.LastIndex(name, "*")
+	if i == -1 {
+		return nil
+	}
+	if i != 0 || !strings.HasPrefix(name, "*.") || dns.CountLabel(name) < 2 {
+		return fmt.Errorf("Invalid wildcard FQDN: %s", name)
+	}
+	return nil
+}
+
+// validateTarget checks that the given record target is a fully qualified
+// domain name.
+func validateTarget(target string) error {
+	if _, ok := dns.IsDomainName(target); !ok || !dns.IsFqdn(target) {
+		return fmt.Errorf("Invalid target FQDN: %s", target)
+	}
+	if strings.Contains(target, "*") {
+		return fmt.Errorf("Wildcard target FQDN not supported: %s", target)
+	}
+	return nil
+}
+
+// SplitMX splits the value of an MX StaticEntry, formatted as
+// "<preference> <exchange>", into its parts.
+func SplitMX(value string) (uint16, string, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return 0, "", fmt.Errorf("Invalid (Preference Exchange) tuple: %s", value)
+	}
+	pref, err := strconv.ParseUint(fields[0], 10, 16)
+	if err != nil {
+		return 0, "", fmt.Errorf("Invalid MX preference: %s", value)
+	}
+	return uint16(pref), fields[1], nil
+}
+
+// validateReverseZones checks that each reverse zone in the list is a properly
+// formatted CIDR. duplicate CIDRs in the list are not allowed.
+// returns nil if the list is empty, or else all CIDRs in the list are valid.
+func validateReverseZones(cidrs []string) error {
+	if len(cidrs) != len(unique(cidrs)) {
+		return fmt.Errorf("duplicate reverse zone specified")
+	}
+	_, err := ReverseZones(cidrs)
+	return err
+}
+
+// validateZones checks validity of the zones served in addition to the
+// given domain
+func validateZones(domain string, zones []Zone) error {
+	seen := map[string]bool{strings.TrimRight(domain, "."): true}
+	for _, z := range zones {
+		name := strings.ToLower(strings.TrimRight(z.Domain, "."))
+		if _, ok := dns.IsDomainName(name); !ok || name == "" {
+			return fmt.Errorf("invalid zone %q", z.Domain)
+		}
+		if seen[name] {
+			return fmt.Errorf("duplicate zone %q", z.Domain)
+		}
+		seen[name] = true
+		for _, f := range z.Frameworks {
+			if f == "" {
+				return fmt.Errorf("empty framework name in zone %q", z.Domain)
 			}
-			break
+		}
+	}
+	return nil
+}
+
+// validateFrameworkTTLs checks that all framework TTLs are positive
+func validateFrameworkTTLs(ttls map[string]int32) error {
+	for name, ttl := range ttls {
+		if ttl <= 0 {
+			return fmt.Errorf("invalid TTL %d for framework %q", ttl, name)
+		}
+	}
+	return nil
+}
+
+// validateTaskIDHashLength checks that the task ID hash length is within the
+// bounds of a base32 encoded 64-bit hash
+func validateTaskIDHashLength(n int) error {
+	if n < 1 || n > maxHashLength {
+		return fmt.Errorf("task ID hash length %d not in [1, %d]", n, maxHashLength)
+	}
+	return nil
+}
+
+// validateClusterCIDRs checks that each cluster network in the list is a
+// properly formatted CIDR. duplicate CIDRs in the list are not allowed.
+func validateClusterCIDRs(cidrs []string) error {
+	if len(cidrs) != len(unique(cidrs)) {
+		return fmt.Errorf("duplicate cluster CIDR specified")
+	}
+	for _, cidr := range cidrs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid cluster CIDR %q: %v", cidr, err)
+		}
+	}
+	return nil
+}
+
+// validateTaskStates checks validity of the states of published tasks. Only
+// non-terminal states are allowed since tasks in terminal states have no
+// addresses to resolve to.
+func validateTaskStates(states []string) error {
+	if len(states) == 0 {
+		return fmt.Errorf("no task states specified")
+	}
+	if len(states) != len(unique(states)) {
+		return fmt.Errorf("duplicate task state specified")
+	}
+	for _, s := range states {
+		switch s {
+		case "TASK_STAGING", "TASK_STARTING", "TASK_RUNNING", "TASK_KILLING", "TASK_UNREACHABLE":
 		default:
-			return conf, fmt.Errorf("Unsupported Record Type: %s", entry.Type)
+			return fmt.Errorf("invalid task state %q", s)
 		}
 	}
+	return nil
+}
 
-	return conf, err
+// validateStateSource checks validity of the API the state of the masters is
+// loaded from
+func validateStateSource(source string) error {
+	switch source {
+	case "state.json", "operator", "subscribe":
+		return nil
+	default:
+		return fmt.Errorf("invalid state source %q", source)
+	}
+}
+
+// validateMesosClient checks validity of the scheme, TLS settings and
+// credentials of the requests to the masters
+func validateMesosClient(c *Config) error {
+	switch c.MesosScheme {
+	case "http":
+		if c.MesosCACertFile != "" || c.MesosCertFile != "" || c.MesosKeyFile != "" {
+			return fmt.Errorf("TLS files specified with scheme %q", c.MesosScheme)
+		}
+	case "https":
+	default:
+		return fmt.Errorf("invalid scheme %q", c.MesosScheme)
+	}
+	if (c.MesosCertFile == "") != (c.MesosKeyFile == "") {
+		return fmt.Errorf("certificate and key must be specified together")
+	}
+	if c.MesosCredentialsFile != "" && c.MesosAuthToken != "" {
+		return fmt.Errorf("both credentials file and token specified")
+	}
+	return nil
+}
+
+// validateHealthCheckMode checks validity of the task health check mode
+func validateHealthCheckMode(mode string) error {
+	switch mode {
+	case "ignore", "prefer-healthy", "healthy-only":
+		return nil
+	default:
+		return fmt.Errorf("invalid health check mode %q", mode)
+	}
+}
+
+// validateTXTLabels checks validity of the task label keys published in TXT
+// records
+func validateTXTLabels(keys []string) error {
+	if len(keys) != len(unique(keys)) {
+		return fmt.Errorf("duplicate TXT label specified")
+	}
+	for _, key := range keys {
+		if key == "" || strings.Contains(key, "=") {
+			return fmt.Errorf("invalid TXT label %q", key)
+		}
+	}
+	return nil
+}
+
+// validateNamingTemplates checks validity of the record naming templates
+func validateNamingTemplates(t NamingTemplates) error {
+	task := []string{"task", "framework", "agent-id"}
+	agent := []string{"agent-id", "hostname"}
+	for _, tc := range []struct {
+		name, tmpl        string
+		allowed, required []string
+		labels            bool
+	}{
+		{"Task", t.Task, task, []string{"task"}, true},
+		{"TaskService", t.TaskService, append(task, "protocol"), []string{"task", "protocol"}, true},
+		{"PortService", t.PortService, append(task, "protocol", "port"), []string{"task", "protocol", "port"}, true},
+		{"Framework", t.Framework, []string{"framework"}, []string{"framework"}, false},
+		{"Agent", t.Agent, agent, []string{"agent-id"}, false},
+		{"AgentHost", t.AgentHost, agent, []string{"hostname"}, false},
+		{"SlaveAddress", t.SlaveAddress, []string{"name"}, []string{"name"}, false},
+		{"Cluster", t.Cluster, []string{"name"}, []string{"name"}, false},
+	} {
+		if err := validateTemplate(tc.tmpl, tc.allowed, tc.required, tc.labels); err != nil {
+			return fmt.Errorf("%s: %v", tc.name, err)
+		}
+	}
+	return nil
+}
+
+// validateTemplate checks that the given template only refers to the allowed
+// variables, refers to all of the required ones and expands to valid names.
+func validateTemplate(tmpl string, allowed, required []string, labels bool) error {
+	vars, err := templateVars(tmpl)
+	if err != nil {
+		return fmt.Errorf("invalid template %q: %v", tmpl, err)
+	}
+
+	sample := map[string]string{}
+	for _, v := range vars {
+		switch {
+		case labels && strings.HasPrefix(v, labelVar) && len(v) > len(labelVar):
+		case contains(allowed, v):
+		default:
+			return fmt.Errorf("invalid template %q: unknown variable {%s}", tmpl, v)
+		}
+		sample[v] = "x"
+	}
+	for _, v := range required {
+		if !contains(vars, v) {
+			return fmt.Errorf("invalid template %q: missing variable {%s}", tmpl, v)
+		}
+	}
+
+	name, err := render(tmpl, sample)
+	if err != nil {
+		return fmt.Errorf("invalid template %q: %v", tmpl, err)
+	}
+	if _, ok := dns.IsDomainName(name); !ok || dns.IsFqdn(name) ||
+		strings.HasPrefix(name, ".") || strings.Contains(name, "..") {
+		return fmt.Errorf("invalid template %q: not a relative domain name", tmpl)
+	}
+	return nil
 }
 
 // validateIPSources checks validity of ip sources
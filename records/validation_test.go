@@ -51,6 +51,220 @@ func TestValidateResolvers(t *testing.T) {
 	}
 }
 
+func TestValidateReverseZones(t *testing.T) {
+	for i, tc := range []validationTest{
+		{nil, true},
+		{[]string{}, true},
+		{[]string{""}, false},
+		{[]string{"10.0.0.1"}, false},
+		{[]string{"10.0.0.0/8"}, true},
+		{[]string{"10.0.0.0/33"}, false},
+		{[]string{"10.16.0.0/12", "192.168.1.0/24"}, true},
+		{[]string{"10.0.0.0/8", "10.0.0.0/8"}, false},
+		{[]string{"fd00::/8"}, true},
+		{[]string{"2001:db8::/32", "10.0.0.0/8"}, true},
+	} {
+		validate(t, i+1, tc, validateReverseZones)
+	}
+}
+
+func TestValidateFrameworkTTLs(t *testing.T) {
+	for i, tc := range []struct {
+		ttls  map[string]int32
+		valid bool
+	}{
+		{nil, true},
+		{map[string]int32{"marathon": 5}, true},
+		{map[string]int32{"marathon": 5, "chronos": 300}, true},
+		{map[string]int32{"marathon": 0}, false},
+		{map[string]int32{"marathon": -1}, false},
+	} {
+		if err := validateFrameworkTTLs(tc.ttls); (err == nil) != tc.valid {
+			t.Errorf("test case %d: %v: unexpected validation result: %v", i+1, tc.ttls, err)
+		}
+	}
+}
+
+func TestValidateTaskIDHashLength(t *testing.T) {
+	for i, tc := range []struct {
+		n     int
+		valid bool
+	}{
+		{-1, false},
+		{0, false},
+		{1, true},
+		{8, true},
+		{13, true},
+		{14, false},
+	} {
+		if err := validateTaskIDHashLength(tc.n); (err == nil) != tc.valid {
+			t.Errorf("test case %d: %d: unexpected validation result: %v", i+1, tc.n, err)
+		}
+	}
+}
+
+func TestValidateClusterCIDRs(t *testing.T) {
+	for i, tc := range []struct {
+		cidrs []string
+		valid bool
+	}{
+		{nil, true},
+		{[]string{"10.0.0.0/8"}, true},
+		{[]string{"10.0.0.0/8", "fd00::/8"}, true},
+		{[]string{"10.0.0.0/8", "10.0.0.0/8"}, false},
+		{[]string{"10.0.0.1"}, false},
+		{[]string{"10.0.0.0/33"}, false},
+	} {
+		if err := validateClusterCIDRs(tc.cidrs); (err == nil) != tc.valid {
+			t.Errorf("test case %d: %v: unexpected validation result: %v", i+1, tc.cidrs, err)
+		}
+	}
+}
+
+func TestValidateNamingTemplates(t *testing.T) {
+	for i, tc := range []struct {
+		f     func(*NamingTemplates)
+		valid bool
+	}{
+		{func(*NamingTemplates) {}, true},
+		{func(t *NamingTemplates) { t.Task = "{task}.{framework}.{label:env}.dc1" }, true},
+		{func(t *NamingTemplates) { t.Task = "{task}" }, true},
+		{func(t *NamingTemplates) { t.Task = "{task}-{agent-id}.{framework}" }, true},
+		{func(t *NamingTemplates) { t.Task = "{framework}" }, false},
+		{func(t *NamingTemplates) { t.Task = "" }, false},
+		{func(t *NamingTemplates) { t.Task = "{task}.{port}" }, false},
+		{func(t *NamingTemplates) { t.Task = "{task}.{label:}" }, false},
+		{func(t *NamingTemplates) { t.Task = "{task}.{framework" }, false},
+		{func(t *NamingTemplates) { t.Task = "{task}}" }, false},
+		{func(t *NamingTemplates) { t.Task = "{task}..{framework}" }, false},
+		{func(t *NamingTemplates) { t.Task = "{task}.{framework}." }, false},
+		{func(t *NamingTemplates) { t.Task = ".{task}" }, false},
+		{func(t *NamingTemplates) { t.TaskService = "_{task}._{protocol}" }, true},
+		{func(t *NamingTemplates) { t.TaskService = "_{task}.{framework}" }, false},
+		{func(t *NamingTemplates) { t.PortService = "_{port}._{task}._{protocol}.{label:env}" }, true},
+		{func(t *NamingTemplates) { t.PortService = "_{task}._{protocol}" }, false},
+		{func(t *NamingTemplates) { t.Framework = "{framework}.frameworks" }, true},
+		{func(t *NamingTemplates) { t.Framework = "{framework}.{label:env}" }, false},
+		{func(t *NamingTemplates) { t.Agent = "{agent-id}.{hostname}" }, true},
+		{func(t *NamingTemplates) { t.Agent = "{hostname}" }, false},
+		{func(t *NamingTemplates) { t.AgentHost = "{hostname}.hosts" }, true},
+		{func(t *NamingTemplates) { t.SlaveAddress = "{name}.hosts" }, true},
+		{func(t *NamingTemplates) { t.SlaveAddress = "slave" }, false},
+		{func(t *NamingTemplates) { t.SlaveAddress = "{name}.{task}" }, false},
+		{func(t *NamingTemplates) { t.Cluster = "{name}.dc1" }, true},
+		{func(t *NamingTemplates) { t.Cluster = "cluster" }, false},
+	} {
+		tmpls := DefaultNamingTemplates()
+		tc.f(&tmpls)
+		if err := validateNamingTemplates(tmpls); (err == nil) != tc.valid {
+			t.Errorf("test case %d: %+v: unexpected validation result: %v", i+1, tmpls, err)
+		}
+	}
+}
+
+func TestValidateZones(t *testing.T) {
+	for i, tc := range []struct {
+		zones []Zone
+		valid bool
+	}{
+		{nil, true},
+		{[]Zone{{Domain: "mesos.corp.example"}}, true},
+		{[]Zone{{Domain: "mesos.corp.example."}, {Domain: "team.mesos", Frameworks: []string{"marathon"}}}, true},
+		{[]Zone{{Domain: ""}}, false},
+		{[]Zone{{Domain: "mesos"}}, false},
+		{[]Zone{{Domain: "Mesos."}}, false},
+		{[]Zone{{Domain: "corp.example"}, {Domain: "corp.example."}}, false},
+		{[]Zone{{Domain: "team.mesos", Frameworks: []string{""}}}, false},
+	} {
+		if err := validateZones("mesos", tc.zones); (err == nil) != tc.valid {
+			t.Errorf("test case %d: %+v: unexpected validation result: %v", i+1, tc.zones, err)
+		}
+	}
+}
+
+func TestValidateTaskStates(t *testing.T) {
+	for i, tc := range []validationTest{
+		{nil, false},
+		{[]string{"TASK_RUNNING"}, true},
+		{[]string{"TASK_STAGING", "TASK_STARTING", "TASK_RUNNING", "TASK_KILLING", "TASK_UNREACHABLE"}, true},
+		{[]string{"TASK_RUNNING", "TASK_RUNNING"}, false},
+		{[]string{"TASK_FINISHED"}, false},
+		{[]string{"running"}, false},
+	} {
+		validate(t, i+1, tc, validateTaskStates)
+	}
+}
+
+func TestValidateStateSource(t *testing.T) {
+	for i, tc := range []struct {
+		source string
+		valid  bool
+	}{
+		{"state.json", true},
+		{"operator", true},
+		{"subscribe", true},
+		{"", false},
+		{"v1", false},
+	} {
+		if err := validateStateSource(tc.source); (err == nil) != tc.valid {
+			t.Errorf("test case %d: %q: unexpected validation result: %v", i+1, tc.source, err)
+		}
+	}
+}
+
+func TestValidateMesosClient(t *testing.T) {
+	for i, tc := range []struct {
+		config Config
+		valid  bool
+	}{
+		{Config{MesosScheme: "http"}, true},
+		{Config{MesosScheme: "https"}, true},
+		{Config{MesosScheme: "https", MesosCACertFile: "ca.pem", MesosCertFile: "cert.pem", MesosKeyFile: "key.pem"}, true},
+		{Config{MesosScheme: "http", MesosCredentialsFile: "credentials.json"}, true},
+		{Config{MesosScheme: "http", MesosAuthToken: "token"}, true},
+		{Config{MesosScheme: ""}, false},
+		{Config{MesosScheme: "ftp"}, false},
+		{Config{MesosScheme: "http", MesosCACertFile: "ca.pem"}, false},
+		{Config{MesosScheme: "https", MesosCertFile: "cert.pem"}, false},
+		{Config{MesosScheme: "https", MesosKeyFile: "key.pem"}, false},
+		{Config{MesosScheme: "http", MesosCredentialsFile: "credentials.json", MesosAuthToken: "token"}, false},
+	} {
+		if err := validateMesosClient(&tc.config); (err == nil) != tc.valid {
+			t.Errorf("test case %d: %+v: unexpected validation result: %v", i+1, tc.config, err)
+		}
+	}
+}
+
+func TestValidateHealthCheckMode(t *testing.T) {
+	for i, tc := range []struct {
+		mode  string
+		valid bool
+	}{
+		{"ignore", true},
+		{"prefer-healthy", true},
+		{"healthy-only", true},
+		{"", false},
+		{"healthy", false},
+	} {
+		if err := validateHealthCheckMode(tc.mode); (err == nil) != tc.valid {
+			t.Errorf("test case %d: %q: unexpected validation result: %v", i+1, tc.mode, err)
+		}
+	}
+}
+
+func TestValidateTXTLabels(t *testing.T) {
+	for i, tc := range []validationTest{
+		{nil, true},
+		{[]string{"team"}, true},
+		{[]string{"team", "canary"}, true},
+		{[]string{""}, false},
+		{[]string{"team=infra"}, false},
+		{[]string{"team", "team"}, false},
+	} {
+		validate(t, i+1, tc, validateTXTLabels)
+	}
+}
+
 type validationTest struct {
 	in    []string
 	valid bool
@@ -137,6 +351,69 @@ func TestValidateStaticEntryFile(t *testing.T) {
 	Cleanup("/tmp/invalid.json", "/tmp/valid.json")
 }
 
+func TestValidateStaticEntry(t *testing.T) {
+	for i, tt := range []struct {
+		StaticEntry
+		valid bool
+	}{
+		{StaticEntry{Fqdn: "hello.world.", Type: "A", Value: "10.0.0.1"}, true},
+		{StaticEntry{Fqdn: "hello.world.", Type: "A", Value: "fd00::1"}, false},
+		{StaticEntry{Fqdn: "hello.world", Type: "A", Value: "10.0.0.1"}, false},
+		{StaticEntry{Fqdn: "hello.world.", Type: "AAAA", Value: "fd00::1"}, true},
+		{StaticEntry{Fqdn: "hello.world.", Type: "AAAA", Value: "10.0.0.1"}, false},
+		{StaticEntry{Fqdn: "_hello._tcp.world.", Type: "SRV", Value: "10.0.0.1:323"}, true},
+		{StaticEntry{Fqdn: "www.world.", Type: "CNAME", Value: "hello.world."}, true},
+		{StaticEntry{Fqdn: "www.world.", Type: "CNAME", Value: "hello.world"}, false},
+		{StaticEntry{Fqdn: "www.world.", Type: "CNAME", Value: ""}, false},
+		{StaticEntry{Fqdn: "world.", Type: "TXT", Value: "owner=infra"}, true},
+		{StaticEntry{Fqdn: "world.", Type: "TXT", Value: ""}, false},
+		{StaticEntry{Fqdn: "world.", Type: "MX", Value: "10 mail.world."}, true},
+		{StaticEntry{Fqdn: "world.", Type: "MX", Value: "mail.world."}, false},
+		{StaticEntry{Fqdn: "world.", Type: "MX", Value: "-1 mail.world."}, false},
+		{StaticEntry{Fqdn: "world.", Type: "MX", Value: "10 mail.world"}, false},
+		{StaticEntry{Fqdn: "1.0.0.10.in-addr.arpa.", Type: "PTR", Value: "hello.world."}, true},
+		{StaticEntry{Fqdn: "10.0.0.1", Type: "PTR", Value: "hello.world."}, true},
+		{StaticEntry{Fqdn: "10.0.0.1", Type: "PTR", Value: "10.0.0.2"}, false},
+		{StaticEntry{Fqdn: "sub.world.", Type: "NS", Value: "ns1.sub.world."}, true},
+		{StaticEntry{Fqdn: "sub.world.", Type: "NS", Value: "ns1"}, false},
+		{StaticEntry{Fqdn: "*.apps.world.", Type: "A", Value: "10.0.0.1"}, true},
+		{StaticEntry{Fqdn: "*.apps.world.", Type: "CNAME", Value: "router.world."}, true},
+		{StaticEntry{Fqdn: "*._tcp.world.", Type: "SRV", Value: "10.0.0.1:323"}, true},
+		{StaticEntry{Fqdn: "*.world.", Type: "TXT", Value: "owner=infra"}, true},
+		{StaticEntry{Fqdn: "*.", Type: "A", Value: "10.0.0.1"}, false},
+		{StaticEntry{Fqdn: "web.*.world.", Type: "A", Value: "10.0.0.1"}, false},
+		{StaticEntry{Fqdn: "web*.world.", Type: "A", Value: "10.0.0.1"}, false},
+		{StaticEntry{Fqdn: "*web.world.", Type: "A", Value: "10.0.0.1"}, false},
+		{StaticEntry{Fqdn: "_x.*._tcp.world.", Type: "SRV", Value: "10.0.0.1:323"}, false},
+		{StaticEntry{Fqdn: "www.world.", Type: "CNAME", Value: "*.world."}, false},
+		{StaticEntry{Fqdn: "*.world.", Type: "NS", Value: "ns1.world."}, false},
+		{StaticEntry{Fqdn: "*.in-addr.arpa.", Type: "PTR", Value: "hello.world."}, false},
+		{StaticEntry{Fqdn: "world.", Type: "SPF", Value: "v=spf1 -all"}, false},
+	} {
+		if err := validateStaticEntry(tt.StaticEntry); (err == nil) != tt.valid {
+			t.Errorf("test #%d: %+v: unexpected validation result: %v", i, tt.StaticEntry, err)
+		}
+	}
+}
+
+func TestValidateStaticEntries(t *testing.T) {
+	www := StaticEntry{Fqdn: "www.world.", Type: "CNAME", Value: "hello.world."}
+	for i, tt := range []struct {
+		entries []StaticEntry
+		valid   bool
+	}{
+		{[]StaticEntry{www}, true},
+		{[]StaticEntry{www, {Fqdn: "web.world.", Type: "CNAME", Value: "hello.world."}}, true},
+		{[]StaticEntry{www, {Fqdn: "www.world.", Type: "CNAME", Value: "web.world."}}, false},
+		{[]StaticEntry{www, {Fqdn: "WWW.world.", Type: "CNAME", Value: "hello.world."}}, false},
+		{[]StaticEntry{www, {Fqdn: "www.world", Type: "A", Value: "10.0.0.1"}}, false},
+	} {
+		if err := validateStaticEntries(tt.entries); (err == nil) != tt.valid {
+			t.Errorf("test #%d: %+v: unexpected validation result: %v", i, tt.entries, err)
+		}
+	}
+}
+
 func CreateTempFile(data, filename string, t *testing.T) {
 	err := ioutil.WriteFile(filename, []byte(data), 0644)
 	if err != nil {
@@ -7,6 +7,7 @@ import (
 	"math/rand"
 	"net"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -30,6 +31,25 @@ type Resolver struct {
 	rsLock  sync.RWMutex
 	rng     *rand.Rand
 
+	// addresses of framework and slave hostnames, kept across reloads
+	hosts *records.HostCache
+
+	// networks of clients within the cluster
+	clusterNets []*net.IPNet
+
+	// guards masters, which the master detector changes while subscribed
+	mastersLock sync.Mutex
+
+	// serializes updates of the records by reloads and events
+	updateLock sync.Mutex
+
+	// signals Subscribe to resubscribe, e.g. after the leader changed
+	resubscribe chan struct{}
+
+	// subscribers to the record changes of reloads
+	watchers  map[chan []records.Change]struct{}
+	watchLock sync.Mutex
+
 	// pluggable external DNS resolution, mainly for unit testing
 	extResolver exchanger.Exchanger
 }
@@ -37,11 +57,23 @@ type Resolver struct {
 // New returns a Resolver with the given version and configuration.
 func New(version string, config records.Config) *Resolver {
 	r := &Resolver{
-		version: version,
-		config:  config,
-		rs:      &records.RecordGenerator{},
-		rng:     rand.New(rand.NewSource(time.Now().UnixNano())),
-		masters: append([]string{""}, config.Masters...),
+		version:     version,
+		config:      config,
+		rs:          &records.RecordGenerator{},
+		rng:         rand.New(rand.NewSource(time.Now().UnixNano())),
+		masters:     append([]string{""}, config.Masters...),
+		resubscribe: make(chan struct{}, 1),
+		hosts: records.NewHostCache(
+			time.Duration(config.HostCacheTTL)*time.Second,
+			time.Duration(config.HostCacheNegativeTTL)*time.Second,
+			config.HostLookupConcurrency,
+		),
+	}
+
+	for _, cidr := range config.ClusterCIDRs {
+		if _, ipnet, err := net.ParseCIDR(cidr); err == nil {
+			r.clusterNets = append(r.clusterNets, ipnet)
+		}
 	}
 
 	if !config.ExternalOn {
@@ -86,11 +118,58 @@ func (res *Resolver) records() *records.RecordGenerator {
 	return res.rs
 }
 
+// visibleRecords returns the current record set as visible to the client at
+// the given address: clients outside of the cluster networks don't see the
+// records of tasks with CLUSTER visibility.
+func (res *Resolver) visibleRecords(client string) *records.RecordGenerator {
+	rs := res.records()
+	if res.isExternal(clientIP(client)) {
+		return rs.External()
+	}
+	return rs
+}
+
+// isExternal returns whether the given client IP is outside of the cluster
+// networks. All clients are within the cluster if there are none configured.
+func (res *Resolver) isExternal(ip net.IP) bool {
+	if len(res.clusterNets) == 0 {
+		return false
+	}
+	for _, ipnet := range res.clusterNets {
+		if ip != nil && ipnet.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// clientIP returns the IP of the given client address, with or without port.
+func clientIP(addr string) net.IP {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	if i := strings.LastIndex(addr, "%"); i != -1 {
+		addr = addr[:i] // IPv6 zone
+	}
+	return net.ParseIP(addr)
+}
+
 // LaunchDNS starts a (TCP and UDP) DNS server for the Resolver,
 // returning a error channel to which errors are asynchronously sent.
 func (res *Resolver) LaunchDNS() <-chan error {
 	// Handers for Mesos requests
 	dns.HandleFunc(res.config.Domain+".", panicRecover(res.HandleMesos))
+	for _, zone := range res.config.Zones {
+		dns.HandleFunc(zone.Domain+".", panicRecover(res.HandleMesos))
+	}
+	// Handlers for reverse lookups of Mesos addresses
+	zones, err := records.ReverseZones(res.config.ReverseZones)
+	if err != nil {
+		logging.Error.Println(err)
+	}
+	for _, zone := range zones {
+		dns.HandleFunc(zone, panicRecover(res.HandleMesos))
+	}
 	// Handler for nonMesos requests
 	dns.HandleFunc(".", panicRecover(res.HandleNonMesos))
 
@@ -129,41 +208,67 @@ func (res *Resolver) Serve(proto string) (<-chan struct{}, <-chan error) {
 	return ch, errCh
 }
 
-// SetMasters sets the given masters.
-// This method is not goroutine-safe.
+// SetMasters sets the given masters, which redirects may then send the
+// credentials to.
 func (res *Resolver) SetMasters(masters []string) {
+	res.config.TrustMasters(masters...)
+	res.mastersLock.Lock()
+	defer res.mastersLock.Unlock()
 	res.masters = masters
 }
 
+// getMasters returns the current masters.
+func (res *Resolver) getMasters() []string {
+	res.mastersLock.Lock()
+	defer res.mastersLock.Unlock()
+	return res.masters
+}
+
 // Reload triggers a new state load from the configured mesos masters.
-// This method is not goroutine-safe.
 func (res *Resolver) Reload() {
-	t := records.RecordGenerator{}
-	err := t.ParseState(res.config, res.masters...)
+	t := records.RecordGenerator{HostCache: res.hosts}
+	err := t.ParseState(res.config, res.getMasters()...)
 
 	if err != nil {
 		logging.VeryVerbose.Println("Warning: master not found; serving only static entries")
 	}
 
+	res.update(&t)
+}
+
+// update replaces the records with the given ones and publishes the changes.
+func (res *Resolver) update(t *records.RecordGenerator) {
+	res.updateLock.Lock()
+	defer res.updateLock.Unlock()
+
+	changes := t.Diff(res.records())
+
 	timestamp := uint32(time.Now().Unix())
 	// may need to refactor for fairness
 	res.rsLock.Lock()
-	defer res.rsLock.Unlock()
 	res.config.SOASerial = timestamp
-	res.rs = &t
+	res.rs = t
+	res.rsLock.Unlock()
 
+	res.publish(changes)
 	logging.PrintCurLog()
 }
 
-// formatSRV returns the SRV resource record for target
-func (res *Resolver) formatSRV(name string, target string) (*dns.SRV, error) {
-	ttl := uint32(res.config.TTL)
+// ttl returns the TTL of the given record, falling back to the configured one.
+func (res *Resolver) ttl(rr records.Record) uint32 {
+	if rr.TTL > 0 {
+		return rr.TTL
+	}
+	return uint32(res.config.TTL)
+}
 
-	h, port, err := net.SplitHostPort(target)
-	if err != nil {
+// formatSRV returns the SRV resource record for rr
+func (res *Resolver) formatSRV(name string, rr records.Record) (*dns.SRV, error) {
+	ttl := res.ttl(rr)
+
+	if _, ok := dns.IsDomainName(rr.Target); !ok {
 		return nil, errors.New("invalid target")
 	}
-	p, _ := strconv.Atoi(port)
 
 	return &dns.SRV{
 		Hdr: dns.RR_Header{
@@ -172,19 +277,19 @@ func (res *Resolver) formatSRV(name string, target string) (*dns.SRV, error) {
 			Class:  dns.ClassINET,
 			Ttl:    ttl,
 		},
-		Priority: 0,
-		Weight:   0,
-		Port:     uint16(p),
-		Target:   h,
+		Priority: rr.Priority,
+		Weight:   rr.Weight,
+		Port:     rr.Port,
+		Target:   rr.Target,
 	}, nil
 }
 
-// returns the A resource record for target
-// assumes target is a well formed IPv4 address
-func (res *Resolver) formatA(dom string, target string) (*dns.A, error) {
-	ttl := uint32(res.config.TTL)
+// returns the A resource record for rr
+// assumes rr.Target is a well formed IPv4 address
+func (res *Resolver) formatA(dom string, rr records.Record) (*dns.A, error) {
+	ttl := res.ttl(rr)
 
-	a := net.ParseIP(target)
+	a := net.ParseIP(rr.Target).To4()
 	if a == nil {
 		return nil, errors.New("invalid target")
 	}
@@ -195,13 +300,111 @@ func (res *Resolver) formatA(dom string, target string) (*dns.A, error) {
 			Rrtype: dns.TypeA,
 			Class:  dns.ClassINET,
 			Ttl:    ttl},
-		A: a.To4(),
+		A: a,
+	}, nil
+}
+
+// returns the AAAA resource record for rr
+// assumes rr.Target is a well formed IPv6 address
+func (res *Resolver) formatAAAA(dom string, rr records.Record) (*dns.AAAA, error) {
+	ttl := res.ttl(rr)
+
+	a := net.ParseIP(rr.Target)
+	if a == nil || a.To4() != nil {
+		return nil, errors.New("invalid target")
+	}
+
+	return &dns.AAAA{
+		Hdr: dns.RR_Header{
+			Name:   dom,
+			Rrtype: dns.TypeAAAA,
+			Class:  dns.ClassINET,
+			Ttl:    ttl},
+		AAAA: a,
+	}, nil
+}
+
+// formatPTR returns the PTR resource record for rr
+func (res *Resolver) formatPTR(dom string, rr records.Record) (*dns.PTR, error) {
+	ttl := res.ttl(rr)
+
+	if _, ok := dns.IsDomainName(rr.Target); !ok {
+		return nil, errors.New("invalid target")
+	}
+
+	return &dns.PTR{
+		Hdr: dns.RR_Header{
+			Name:   dom,
+			Rrtype: dns.TypePTR,
+			Class:  dns.ClassINET,
+			Ttl:    ttl},
+		Ptr: rr.Target,
+	}, nil
+}
+
+// formatCNAME returns the CNAME resource record for rr
+func (res *Resolver) formatCNAME(dom string, rr records.Record) (*dns.CNAME, error) {
+	ttl := res.ttl(rr)
+
+	if _, ok := dns.IsDomainName(rr.Target); !ok {
+		return nil, errors.New("invalid target")
+	}
+
+	return &dns.CNAME{
+		Hdr: dns.RR_Header{
+			Name:   dom,
+			Rrtype: dns.TypeCNAME,
+			Class:  dns.ClassINET,
+			Ttl:    ttl},
+		Target: rr.Target,
+	}, nil
+}
+
+// formatTXT returns the TXT resource record for rr, split into strings of at
+// most 255 bytes as required by RFC 1035.
+func (res *Resolver) formatTXT(dom string, rr records.Record) (*dns.TXT, error) {
+	ttl := res.ttl(rr)
+
+	txt := rr.Target
+	var ss []string
+	for len(txt) > 255 {
+		ss, txt = append(ss, txt[:255]), txt[255:]
+	}
+	ss = append(ss, txt)
+
+	return &dns.TXT{
+		Hdr: dns.RR_Header{
+			Name:   dom,
+			Rrtype: dns.TypeTXT,
+			Class:  dns.ClassINET,
+			Ttl:    ttl},
+		Txt: ss,
+	}, nil
+}
+
+// formatMX returns the MX resource record for rr
+func (res *Resolver) formatMX(dom string, rr records.Record) (*dns.MX, error) {
+	ttl := res.ttl(rr)
+
+	if _, ok := dns.IsDomainName(rr.Target); !ok {
+		return nil, errors.New("invalid target")
+	}
+
+	return &dns.MX{
+		Hdr: dns.RR_Header{
+			Name:   dom,
+			Rrtype: dns.TypeMX,
+			Class:  dns.ClassINET,
+			Ttl:    ttl},
+		Preference: rr.Priority,
+		Mx:         rr.Target,
 	}, nil
 }
 
-// formatSOA returns the SOA resource record for the mesos domain
+// formatSOA returns the SOA resource record for the zone of the given name
 func (res *Resolver) formatSOA(dom string) (*dns.SOA, error) {
 	ttl := uint32(res.config.TTL)
+	zone := res.config.ZoneOf(dom)
 
 	return &dns.SOA{
 		Hdr: dns.RR_Header{
@@ -210,19 +413,23 @@ func (res *Resolver) formatSOA(dom string) (*dns.SOA, error) {
 			Class:  dns.ClassINET,
 			Ttl:    ttl,
 		},
-		Ns:      res.config.SOARname,
-		Mbox:    res.config.SOAMname,
+		Ns:      zone.SOARname,
+		Mbox:    zone.SOAMname,
 		Serial:  res.config.SOASerial,
-		Refresh: res.config.SOARefresh,
-		Retry:   res.config.SOARetry,
-		Expire:  res.config.SOAExpire,
+		Refresh: zone.SOARefresh,
+		Retry:   zone.SOARetry,
+		Expire:  zone.SOAExpire,
 		Minttl:  ttl,
 	}, nil
 }
 
-// formatNS returns the NS  record for the mesos domain
-func (res *Resolver) formatNS(dom string) (*dns.NS, error) {
-	ttl := uint32(res.config.TTL)
+// formatNS returns the NS record for rr
+func (res *Resolver) formatNS(dom string, rr records.Record) (*dns.NS, error) {
+	ttl := res.ttl(rr)
+
+	if _, ok := dns.IsDomainName(rr.Target); !ok {
+		return nil, errors.New("invalid target")
+	}
 
 	return &dns.NS{
 		Hdr: dns.RR_Header{
@@ -231,7 +438,7 @@ func (res *Resolver) formatNS(dom string) (*dns.NS, error) {
 			Class:  dns.ClassINET,
 			Ttl:    ttl,
 		},
-		Ns: res.config.SOAMname,
+		Ns: rr.Target,
 	}, nil
 }
 
@@ -246,6 +453,120 @@ func shuffleAnswers(rng *rand.Rand, answers []dns.RR) []dns.RR {
 	return answers
 }
 
+// orderSRVs reorders the SRV records among the given answers as per RFC 2782:
+// by ascending priority and, within each priority, by weighted random
+// selection. Other answers keep their positions. Answers are left as they are
+// if all SRV records have priority and weight 0.
+func orderSRVs(rng *rand.Rand, answers []dns.RR) []dns.RR {
+	var idx []int
+	var srvs []*dns.SRV
+	var weighted bool
+	for i, rr := range answers {
+		if srv, ok := rr.(*dns.SRV); ok {
+			idx, srvs = append(idx, i), append(srvs, srv)
+			weighted = weighted || srv.Priority != 0 || srv.Weight != 0
+		}
+	}
+	if !weighted {
+		return answers
+	}
+
+	sort.Stable(byPriority(srvs))
+	for i, j := 0, 0; i < len(srvs); i = j {
+		for j = i + 1; j < len(srvs) && srvs[j].Priority == srvs[i].Priority; j++ {
+		}
+		selectWeighted(rng, srvs[i:j])
+	}
+
+	for k, i := range idx {
+		answers[i] = srvs[k]
+	}
+	return answers
+}
+
+// selectWeighted orders SRV records of the same priority by repeatedly
+// selecting one of the remaining records with a probability proportional to
+// its weight, as per RFC 2782. Records of weight 0 have a very small chance of
+// being selected, unless all remaining records have weight 0.
+func selectWeighted(rng *rand.Rand, srvs []*dns.SRV) {
+	for i := range srvs {
+		var sum int
+		for _, srv := range srvs[i:] {
+			sum += int(srv.Weight)
+		}
+
+		if sum == 0 {
+			pick := i + rng.Intn(len(srvs)-i)
+			srvs[i], srvs[pick] = srvs[pick], srvs[i]
+			continue
+		}
+
+		// records of weight 0 are ordered first, so they're only selected
+		// if r is 0
+		r, running, pick := rng.Intn(sum+1), 0, -1
+		for k := i; pick == -1 && r == 0 && k < len(srvs); k++ {
+			if srvs[k].Weight == 0 {
+				pick = k
+			}
+		}
+		for k := i; pick == -1; k++ {
+			if running += int(srvs[k].Weight); srvs[k].Weight > 0 && running >= r {
+				pick = k
+			}
+		}
+		srvs[i], srvs[pick] = srvs[pick], srvs[i]
+	}
+}
+
+// byPriority sorts SRV records by ascending priority.
+type byPriority []*dns.SRV
+
+func (s byPriority) Len() int           { return len(s) }
+func (s byPriority) Less(i, j int) bool { return s[i].Priority < s[j].Priority }
+func (s byPriority) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// preferHealthy reorders the answers of the records stored under the given
+// owner so that those of healthy tasks come first, retaining the relative
+// order of both groups.
+func preferHealthy(rs *records.RecordGenerator, owner string, answers []dns.RR) []dns.RR {
+	healthy := make([]dns.RR, 0, len(answers))
+	var unhealthy []dns.RR
+	for _, rr := range answers {
+		if isUnhealthy(rs, owner, rr) {
+			unhealthy = append(unhealthy, rr)
+		} else {
+			healthy = append(healthy, rr)
+		}
+	}
+	copy(answers, append(healthy, unhealthy...))
+	return answers
+}
+
+// isUnhealthy returns whether the given A, AAAA or SRV answer originates from
+// the record of an unhealthy task among those stored under the given owner,
+// which differs from the name of the answer for wildcard matches and CNAME
+// targets.
+func isUnhealthy(rs *records.RecordGenerator, owner string, rr dns.RR) bool {
+	var rtype, target string
+	var port uint16
+	switch rr := rr.(type) {
+	case *dns.A:
+		rtype, target = "A", rr.A.String()
+	case *dns.AAAA:
+		rtype, target = "AAAA", rr.AAAA.String()
+	case *dns.SRV:
+		rtype, target, port = "SRV", rr.Target, rr.Port
+	default:
+		return false
+	}
+	for _, r := range rs.Records(owner, rtype) {
+		if r.Target == target && r.Port == port {
+			return r.Unhealthy
+		}
+	}
+	return false
+}
+
 // HandleNonMesos handles non-mesos queries by recursing to a configured
 // external resolver.
 func (res *Resolver) HandleNonMesos(w dns.ResponseWriter, r *dns.Msg) {
@@ -292,9 +613,12 @@ func (res *Resolver) HandleNonMesos(w dns.ResponseWriter, r *dns.Msg) {
 	reply(w, m)
 }
 
+// maxCNAMEChain bounds the number of CNAME records followed in a single answer.
+const maxCNAMEChain = 8
+
 // HandleMesos is a resolver request handler that responds to a resource
 // question with resource answer(s)
-// it can handle {A, SRV, ANY}
+// it can handle {A, AAAA, SRV, PTR, CNAME, TXT, MX, SOA, NS, ANY}
 func (res *Resolver) HandleMesos(w dns.ResponseWriter, r *dns.Msg) {
 	logging.CurLog.MesosRequests.Inc()
 
@@ -305,30 +629,66 @@ func (res *Resolver) HandleMesos(w dns.ResponseWriter, r *dns.Msg) {
 	m.SetReply(r)
 
 	var errs multiError
-	rs := res.records()
-	name := strings.ToLower(cleanWild(r.Question[0].Name))
+	rs := res.visibleRecords(w.RemoteAddr().String())
+	name := strings.ToLower(r.Question[0].Name)
+	owner := r.Question[0].Name
+
+	// follow CNAMEs within the zone unless they're being asked for
+	switch r.Question[0].Qtype {
+	case dns.TypeCNAME, dns.TypeANY:
+	default:
+		var err error
+		if name, err = res.chaseCNAME(rs, name, m); err != nil {
+			errs.Add(err)
+		}
+		if len(m.Answer) > 0 {
+			owner = name
+		}
+	}
+	chain := len(m.Answer)
+
 	switch r.Question[0].Qtype {
 	case dns.TypeSRV:
-		errs.Add(res.handleSRV(rs, name, m, r))
+		errs.Add(res.handleSRV(rs, name, owner, m))
 	case dns.TypeA:
 		errs.Add(res.handleA(rs, name, m))
+	case dns.TypeAAAA:
+		errs.Add(res.handleAAAA(rs, name, m))
+	case dns.TypePTR:
+		errs.Add(res.handlePTR(rs, name, m))
+	case dns.TypeCNAME:
+		errs.Add(res.handleCNAME(rs, name, m))
+	case dns.TypeTXT:
+		errs.Add(res.handleTXT(rs, name, m))
+	case dns.TypeMX:
+		errs.Add(res.handleMX(rs, name, m))
 	case dns.TypeSOA:
 		errs.Add(res.handleSOA(m, r))
 	case dns.TypeNS:
-		errs.Add(res.handleNS(m, r))
+		errs.Add(res.handleNS(rs, name, m, r))
 	case dns.TypeANY:
 		errs.Add(
-			res.handleSRV(rs, name, m, r),
+			res.handleSRV(rs, name, owner, m),
 			res.handleA(rs, name, m),
+			res.handleAAAA(rs, name, m),
+			res.handlePTR(rs, name, m),
+			res.handleCNAME(rs, name, m),
+			res.handleTXT(rs, name, m),
+			res.handleMX(rs, name, m),
 			res.handleSOA(m, r),
-			res.handleNS(m, r),
+			res.handleNS(rs, name, m, r),
 		)
 	}
 
 	if len(m.Answer) == 0 {
 		errs.Add(res.handleEmpty(rs, name, m, r))
 	} else {
-		shuffleAnswers(res.rng, m.Answer)
+		// keep CNAME chains in order
+		shuffleAnswers(res.rng, m.Answer[chain:])
+		orderSRVs(res.rng, m.Answer[chain:])
+		if res.config.HealthCheckMode == "prefer-healthy" {
+			preferHealthy(rs, rs.Match(name), m.Answer[chain:])
+		}
 		logging.CurLog.MesosSuccess.Inc()
 	}
 
@@ -340,36 +700,126 @@ func (res *Resolver) HandleMesos(w dns.ResponseWriter, r *dns.Msg) {
 	reply(w, m)
 }
 
-func (res *Resolver) handleSRV(rs *records.RecordGenerator, name string, m, r *dns.Msg) error {
+// chaseCNAME appends the chain of CNAME records starting at name to the answer
+// section and returns the name the chain ends at. Only the records of the
+// given RecordGenerator are followed.
+func (res *Resolver) chaseCNAME(rs *records.RecordGenerator, name string, m *dns.Msg) (string, error) {
+	for i := 0; i < maxCNAMEChain; i++ {
+		targets := rs.CNAMEs[rs.Match(name)]
+		if len(targets) == 0 {
+			return name, nil
+		}
+		rr, err := res.formatCNAME(name, targets[0])
+		if err != nil {
+			return name, err
+		}
+		m.Answer = append(m.Answer, rr)
+		name = strings.ToLower(targets[0].Target)
+	}
+	return name, fmt.Errorf("CNAME chain of %q exceeds %d records", m.Question[0].Name, maxCNAMEChain)
+}
+
+func (res *Resolver) handleSRV(rs *records.RecordGenerator, name, owner string, m *dns.Msg) error {
 	var errs multiError
-	for _, srv := range rs.SRVs[name] {
-		srvRR, err := res.formatSRV(r.Question[0].Name, srv)
+	for _, srv := range rs.SRVs[rs.Match(name)] {
+		srvRR, err := res.formatSRV(owner, srv)
 		if err != nil {
 			errs.Add(err)
 			continue
 		}
 
 		m.Answer = append(m.Answer, srvRR)
-		host := strings.Split(srv, ":")[0]
-		if len(rs.As[host]) == 0 {
+		host := srvRR.Target
+		key := rs.Match(host)
+
+		if len(rs.As[key]) != 0 {
+			if aRR, err := res.formatA(host, rs.As[key][0]); err != nil {
+				errs.Add(err)
+			} else {
+				m.Extra = append(m.Extra, aRR)
+			}
+		}
+
+		if len(rs.AAAAs[key]) != 0 {
+			if aaaaRR, err := res.formatAAAA(host, rs.AAAAs[key][0]); err != nil {
+				errs.Add(err)
+			} else {
+				m.Extra = append(m.Extra, aaaaRR)
+			}
+		}
+	}
+	return errs
+}
+
+func (res *Resolver) handleA(rs *records.RecordGenerator, name string, m *dns.Msg) error {
+	var errs multiError
+	for _, a := range rs.As[rs.Match(name)] {
+		rr, err := res.formatA(name, a)
+		if err != nil {
+			errs.Add(err)
+			continue
+		}
+		m.Answer = append(m.Answer, rr)
+	}
+	return errs
+}
+
+func (res *Resolver) handleAAAA(rs *records.RecordGenerator, name string, m *dns.Msg) error {
+	var errs multiError
+	for _, a := range rs.AAAAs[rs.Match(name)] {
+		rr, err := res.formatAAAA(name, a)
+		if err != nil {
+			errs.Add(err)
 			continue
 		}
+		m.Answer = append(m.Answer, rr)
+	}
+	return errs
+}
 
-		aRR, err := res.formatA(host, rs.As[host][0])
+func (res *Resolver) handlePTR(rs *records.RecordGenerator, name string, m *dns.Msg) error {
+	var errs multiError
+	for _, ptr := range rs.PTRs[name] {
+		rr, err := res.formatPTR(name, ptr)
 		if err != nil {
 			errs.Add(err)
 			continue
 		}
+		m.Answer = append(m.Answer, rr)
+	}
+	return errs
+}
 
-		m.Extra = append(m.Extra, aRR)
+func (res *Resolver) handleCNAME(rs *records.RecordGenerator, name string, m *dns.Msg) error {
+	var errs multiError
+	for _, target := range rs.CNAMEs[rs.Match(name)] {
+		rr, err := res.formatCNAME(name, target)
+		if err != nil {
+			errs.Add(err)
+			continue
+		}
+		m.Answer = append(m.Answer, rr)
 	}
 	return errs
 }
 
-func (res *Resolver) handleA(rs *records.RecordGenerator, name string, m *dns.Msg) error {
+func (res *Resolver) handleTXT(rs *records.RecordGenerator, name string, m *dns.Msg) error {
 	var errs multiError
-	for _, a := range rs.As[name] {
-		rr, err := res.formatA(name, a)
+	for _, txt := range rs.TXTs[rs.Match(name)] {
+		rr, err := res.formatTXT(name, txt)
+		if err != nil {
+			errs.Add(err)
+			continue
+		}
+		m.Answer = append(m.Answer, rr)
+	}
+	return errs
+}
+
+func (res *Resolver) handleMX(rs *records.RecordGenerator, name string, m *dns.Msg) error {
+	var errs multiError
+	for _, mx := range rs.MXs[rs.Match(name)] {
+		rr, err := res.formatMX(name, mx)
 		if err != nil {
 			errs.Add(err)
 			continue
@@ -388,8 +838,23 @@ func (res *Resolver) handleSOA(m, r *dns.Msg) error {
 	return nil
 }
 
-func (res *Resolver) handleNS(m, r *dns.Msg) error {
-	rr, err := res.formatNS(r.Question[0].Name)
+func (res *Resolver) handleNS(rs *records.RecordGenerator, name string, m, r *dns.Msg) error {
+	// static NS records
+	if nss := rs.NSs[name]; len(nss) > 0 {
+		var errs multiError
+		for _, ns := range nss {
+			rr, err := res.formatNS(name, ns)
+			if err != nil {
+				errs.Add(err)
+				continue
+			}
+			m.Answer = append(m.Answer, rr)
+		}
+		return errs
+	}
+
+	zone := res.config.ZoneOf(r.Question[0].Name)
+	rr, err := res.formatNS(r.Question[0].Name, records.Record{Target: zone.SOAMname})
 	logging.Error.Println("NS request")
 	if err != nil {
 		return err
@@ -406,8 +871,9 @@ func (res *Resolver) handleEmpty(rs *records.RecordGenerator, name string, m, r
 		return nil
 	}
 
+	// NODATA if the name exists with records of other types
 	m.Rcode = dns.RcodeNameError
-	if qType == dns.TypeAAAA && len(rs.SRVs[name])+len(rs.As[name]) > 0 {
+	if rs.Exists(rs.Match(name)) {
 		m.Rcode = dns.RcodeSuccess
 	}
 
@@ -454,6 +920,11 @@ func (res *Resolver) configureHTTP() {
 	ws.Route(ws.GET("/v1/hosts/{host}").To(res.RestHost))
 	ws.Route(ws.GET("/v1/hosts/{host}/ports").To(res.RestPorts))
 	ws.Route(ws.GET("/v1/services/{service}").To(res.RestService))
+	ws.Route(ws.GET("/v1/records/{name}").To(res.RestRecords))
+	ws.Route(ws.GET("/v1/agents").To(res.RestAgents))
+	ws.Route(ws.GET("/v1/zones").To(res.RestZones))
+	ws.Route(ws.GET("/v1/zones/{zone}").To(res.RestZone))
+	ws.Route(ws.GET("/v1/watch").To(res.RestWatch))
 	restful.Add(ws)
 }
 
@@ -481,7 +952,15 @@ func (res *Resolver) LaunchHTTP() <-chan error {
 
 // RestConfig handles HTTP requests of Resolver configuration.
 func (res *Resolver) RestConfig(req *restful.Request, resp *restful.Response) {
-	if err := resp.WriteAsJson(res.config); err != nil {
+	if err := resp.WriteAsJson(res.config.Redacted()); err != nil {
+		logging.Error.Println(err)
+	}
+}
+
+// RestZones handles HTTP requests listing the authoritative zones.
+func (res *Resolver) RestZones(req *restful.Request, resp *restful.Response) {
+	zones := append([]records.Zone{res.config.ZoneOf(res.config.Domain)}, res.config.Zones...)
+	if err := resp.WriteAsJson(zones); err != nil {
 		logging.Error.Println(err)
 	}
 }
@@ -498,25 +977,28 @@ func (res *Resolver) RestVersion(req *restful.Request, resp *restful.Response) {
 	}
 }
 
-// RestHost handles HTTP requests of DNS A records of the given host.
+// RestHost handles HTTP requests of DNS A and AAAA records of the given host.
 func (res *Resolver) RestHost(req *restful.Request, resp *restful.Response) {
 	host := req.PathParameter("host")
-	// clean up host name
-	dom := strings.ToLower(cleanWild(host))
+	dom := strings.ToLower(host)
 	if dom[len(dom)-1] != '.' {
 		dom += "."
 	}
-	rs := res.records()
+	rs := res.visibleRecords(req.Request.RemoteAddr)
 
 	type record struct {
 		Host string `json:"host"`
 		IP   string `json:"ip"`
 	}
 
-	aRRs := rs.As[dom]
-	records := make([]record, 0, len(aRRs))
+	key := rs.Match(dom)
+	aRRs, aaaaRRs := rs.As[key], rs.AAAAs[key]
+	records := make([]record, 0, len(aRRs)+len(aaaaRRs))
 	for _, ip := range aRRs {
-		records = append(records, record{dom, ip})
+		records = append(records, record{dom, ip.Target})
+	}
+	for _, ip := range aaaaRRs {
+		records = append(records, record{dom, ip.Target})
 	}
 
 	if len(records) == 0 {
@@ -527,7 +1009,7 @@ func (res *Resolver) RestHost(req *restful.Request, resp *restful.Response) {
 		logging.Error.Println(err)
 	}
 
-	stats(dom, res.config.Domain+".", len(aRRs) > 0)
+	stats(dom, res.config.Domain+".", len(aRRs)+len(aaaaRRs) > 0)
 }
 
 func stats(domain, zone string, success bool) {
@@ -555,29 +1037,30 @@ func (res *Resolver) RestPorts(req *restful.Request, resp *restful.Response) {
 // RestService handles HTTP requests of DNS SRV records for the given name.
 func (res *Resolver) RestService(req *restful.Request, resp *restful.Response) {
 	service := req.PathParameter("service")
-	// clean up service name
-	dom := strings.ToLower(cleanWild(service))
+	dom := strings.ToLower(service)
 	if dom[len(dom)-1] != '.' {
 		dom += "."
 	}
-	rs := res.records()
+	rs := res.visibleRecords(req.Request.RemoteAddr)
 
 	type record struct {
-		Service string `json:"service"`
-		Host    string `json:"host"`
-		IP      string `json:"ip"`
-		Port    string `json:"port"`
+		Service  string `json:"service"`
+		Host     string `json:"host"`
+		IP       string `json:"ip"`
+		Port     string `json:"port"`
+		PortName string `json:"port_name,omitempty"`
 	}
 
-	srvRRs := rs.SRVs[dom]
+	srvRRs := rs.SRVs[rs.Match(dom)]
 	records := make([]record, 0, len(srvRRs))
 	for _, s := range srvRRs {
-		host, port, _ := net.SplitHostPort(s)
 		var ip string
-		if r := rs.As[host]; len(r) != 0 {
-			ip = r[0]
+		if r := rs.As[rs.Match(s.Target)]; len(r) != 0 {
+			ip = r[0].Target
+		} else if r := rs.AAAAs[rs.Match(s.Target)]; len(r) != 0 {
+			ip = r[0].Target
 		}
-		records = append(records, record{service, host, ip, port})
+		records = append(records, record{service, s.Target, ip, strconv.Itoa(int(s.Port)), s.PortName})
 	}
 
 	if len(records) == 0 {
@@ -591,6 +1074,112 @@ func (res *Resolver) RestService(req *restful.Request, resp *restful.Response) {
 	stats(dom, res.config.Domain+".", len(srvRRs) > 0)
 }
 
+// RestAgents handles HTTP requests listing the names, IP addresses and port
+// of every individual slave.
+func (res *Resolver) RestAgents(req *restful.Request, resp *restful.Response) {
+	rs := res.records()
+
+	type agent struct {
+		ID    string   `json:"id"`
+		Names []string `json:"names"`
+		IPs   []string `json:"ips"`
+		Port  string   `json:"port"`
+	}
+
+	agents := map[string]*agent{}
+	get := func(id string) *agent {
+		a, ok := agents[id]
+		if !ok {
+			a = &agent{ID: id, Names: []string{}, IPs: []string{}}
+			agents[id] = a
+		}
+		return a
+	}
+
+	// the names of individual slaves are the targets of their own SRV records,
+	// e.g. _slave._tcp.<slave-id>.slave.domain.
+	for name, rrs := range rs.SRVs {
+		for _, rr := range rrs {
+			if rr.Class != records.SlaveClass ||
+				(name != "_slave._tcp."+rr.Target && name != "_agent._tcp."+rr.Target) {
+				continue
+			}
+			a := get(rr.SlaveID)
+			a.Port = strconv.Itoa(int(rr.Port))
+			a.Names = appendUnique(a.Names, rr.Target)
+			for _, store := range []map[string][]records.Record{rs.As, rs.AAAAs} {
+				for _, ip := range store[rr.Target] {
+					a.IPs = appendUnique(a.IPs, ip.Target)
+				}
+			}
+		}
+	}
+
+	ids := make([]string, 0, len(agents))
+	for id := range agents {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	list := make([]agent, 0, len(ids))
+	for _, id := range ids {
+		a := agents[id]
+		sort.Strings(a.Names)
+		sort.Strings(a.IPs)
+		list = append(list, *a)
+	}
+
+	if err := resp.WriteAsJson(list); err != nil {
+		logging.Error.Println(err)
+	}
+}
+
+// appendUnique appends s to ss unless it's already contained in it.
+func appendUnique(ss []string, s string) []string {
+	for _, e := range ss {
+		if e == s {
+			return ss
+		}
+	}
+	return append(ss, s)
+}
+
+// RestRecords handles HTTP requests of DNS records of any type for the given
+// name.
+func (res *Resolver) RestRecords(req *restful.Request, resp *restful.Response) {
+	name := req.PathParameter("name")
+	dom := strings.ToLower(name)
+	if dom[len(dom)-1] != '.' {
+		dom += "."
+	}
+	rs := res.visibleRecords(req.Request.RemoteAddr)
+
+	type record struct {
+		Name  string `json:"name"`
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	}
+
+	var rrs []record
+	key := rs.Match(dom)
+	for _, rtype := range records.RecordTypes {
+		for _, rr := range rs.Records(key, rtype) {
+			rrs = append(rrs, record{dom, rtype, rr.String()})
+		}
+	}
+
+	found := len(rrs) > 0
+	if !found {
+		rrs = append(rrs, record{})
+	}
+
+	if err := resp.WriteAsJson(rrs); err != nil {
+		logging.Error.Println(err)
+	}
+
+	stats(dom, res.config.Domain+".", found)
+}
+
 // panicRecover catches any panics from the resolvers and sets an error
 // code of server failure
 func panicRecover(f func(w dns.ResponseWriter, r *dns.Msg)) func(w dns.ResponseWriter, r *dns.Msg) {
@@ -607,15 +1196,6 @@ func panicRecover(f func(w dns.ResponseWriter, r *dns.Msg)) func(w dns.ResponseW
 	}
 }
 
-// cleanWild strips any wildcards out thus mapping cleanly to the
-// original serviceName
-func cleanWild(name string) string {
-	if strings.Contains(name, ".*") {
-		return strings.Replace(name, ".*", "", -1)
-	}
-	return name
-}
-
 type multiError []error
 
 func (e multiError) Add(err ...error) multiError {
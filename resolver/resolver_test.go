@@ -18,7 +18,6 @@ import (
 	"github.com/mesosphere/mesos-dns/exchanger"
 	"github.com/mesosphere/mesos-dns/logging"
 	"github.com/mesosphere/mesos-dns/records"
-	"github.com/mesosphere/mesos-dns/records/labels"
 	"github.com/mesosphere/mesos-dns/records/state"
 	"github.com/miekg/dns"
 )
@@ -28,17 +27,6 @@ func init() {
 	logging.SetupLogs()
 }
 
-// dig @127.0.0.1 -p 8053 "bob.*.mesos" ANY
-func TestCleanWild(t *testing.T) {
-	dom := "bob.*.mesos"
-
-	stripped := cleanWild(dom)
-
-	if stripped != "bob.mesos" {
-		t.Error("not stripping domain")
-	}
-}
-
 func TestShuffleAnswers(t *testing.T) {
 	var res Resolver
 
@@ -46,7 +34,7 @@ func TestShuffleAnswers(t *testing.T) {
 
 	for i := 0; i < 10; i++ {
 		name := "10.0.0." + strconv.Itoa(i)
-		rr, err := res.formatA("blah.com", name)
+		rr, err := res.formatA("blah.com", records.Record{Target: name})
 		if err != nil {
 			t.Error(err)
 		}
@@ -75,14 +63,177 @@ func TestShuffleAnswers(t *testing.T) {
 	}
 }
 
+func TestOrderSRVs(t *testing.T) {
+	srv := "_web._tcp.marathon.mesos."
+	a := A(RRHeader("web.marathon.mesos.", dns.TypeA, 60), net.ParseIP("10.0.0.1"))
+	stable := SRV(RRHeader(srv, dns.TypeSRV, 60), "stable.marathon.mesos.", 80, 0, 90)
+	canary := SRV(RRHeader(srv, dns.TypeSRV, 60), "canary.marathon.mesos.", 80, 0, 10)
+	backup := SRV(RRHeader(srv, dns.TypeSRV, 60), "backup.marathon.mesos.", 80, 1, 0)
+
+	rng := rand.New(rand.NewSource(0))
+	var stableFirst int
+	for i := 0; i < 1000; i++ {
+		answers := orderSRVs(rng, []dns.RR{backup, a, canary, stable})
+		if answers[1] != a {
+			t.Fatalf("non-SRV answer moved: %v", answers)
+		}
+		if answers[3] != backup {
+			t.Fatalf("lower priority answer not last: %v", answers)
+		}
+		if answers[0] == stable {
+			stableFirst++
+		}
+	}
+	// stable is selected first with a probability of 90%
+	if stableFirst < 850 || stableFirst > 950 {
+		t.Errorf("stable answer first in %d of 1000 orderings", stableFirst)
+	}
+
+	// unweighted answers are left in their shuffled order
+	plain := []dns.RR{
+		SRV(RRHeader(srv, dns.TypeSRV, 60), "web-1.marathon.mesos.", 80, 0, 0),
+		SRV(RRHeader(srv, dns.TypeSRV, 60), "web-0.marathon.mesos.", 80, 0, 0),
+	}
+	want := []dns.RR{plain[0], plain[1]}
+	if got := orderSRVs(rng, plain); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPreferHealthy(t *testing.T) {
+	res := fakeDNS(t)
+
+	name := "liquor-store.marathon.mesos."
+	srv := "_http._liquor-store._tcp.marathon.mesos."
+	for i, tt := range []struct {
+		owner   string
+		answers []dns.RR
+	}{
+		{name, []dns.RR{
+			A(RRHeader(name, dns.TypeA, 60), net.ParseIP("10.3.0.2")),
+			A(RRHeader(name, dns.TypeA, 60), net.ParseIP("10.3.0.1")),
+		}},
+		{srv, []dns.RR{
+			SRV(RRHeader(srv, dns.TypeSRV, 60), "liquor-store-ahdjzdbz-1.marathon.mesos.", 80, 0, 0),
+			SRV(RRHeader(srv, dns.TypeSRV, 60), "liquor-store-rn76murd-0.marathon.mesos.", 80, 0, 0),
+		}},
+	} {
+		want := []dns.RR{tt.answers[1], tt.answers[0]}
+		if got := preferHealthy(res.records(), tt.owner, tt.answers); !reflect.DeepEqual(got, want) {
+			t.Errorf("test #%d: got %v, want %v", i, got, want)
+		}
+	}
+
+	// answers of wildcard matches come from the records of the wildcard
+	res = fakeDNS(t, func(c *records.Config) {
+		c.TaskWildcards = true
+		c.HealthCheckMode = "prefer-healthy"
+	})
+	for i := 0; i < 4; i++ {
+		rw := ResponseRecorder{}
+		res.HandleMesos(&rw, Message(Question("www.liquor-store.marathon.mesos.", dns.TypeA)))
+		if len(rw.Msg.Answer) != 2 {
+			t.Fatalf("got answers %v, want 2", rw.Msg.Answer)
+		}
+		if got := rw.Msg.Answer[0].(*dns.A).A.String(); got != "10.3.0.1" {
+			t.Errorf("query #%d: got %s first, want the healthy 10.3.0.1", i, got)
+		}
+	}
+}
+
+func TestVisibility(t *testing.T) {
+	res := fakeDNS(t)
+	_, ipnet, _ := net.ParseCIDR("10.0.0.0/8")
+	res.clusterNets = []*net.IPNet{ipnet}
+
+	for i, tt := range []struct {
+		client string
+		rcode  int
+	}{
+		{"10.1.2.3", dns.RcodeSuccess},
+		{"192.168.1.1", dns.RcodeNameError},
+		{"", dns.RcodeNameError},
+	} {
+		rw := ResponseRecorder{Remote: net.IPAddr{IP: net.ParseIP(tt.client)}}
+		m := Message(Question("liquor-store.marathon.mesos.", dns.TypeA))
+		res.HandleMesos(&rw, m)
+		if got := rw.Msg.Rcode; got != tt.rcode {
+			t.Errorf("test #%d: %q: got rcode %d, want %d", i, tt.client, got, tt.rcode)
+		}
+	}
+
+	// records without CLUSTER visibility are visible to all clients
+	if rs := res.visibleRecords("192.168.1.1:53"); len(rs.As["nginx.marathon.mesos."]) == 0 {
+		t.Error("nginx.marathon.mesos. not visible to external clients")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	for i, tt := range []struct {
+		addr string
+		want net.IP
+	}{
+		{"10.1.2.3", net.ParseIP("10.1.2.3")},
+		{"10.1.2.3:53", net.ParseIP("10.1.2.3")},
+		{"[fe80::1%eth0]:53", net.ParseIP("fe80::1")},
+		{"fe80::1%eth0", net.ParseIP("fe80::1")},
+		{"<nil>", nil},
+	} {
+		if got := clientIP(tt.addr); !got.Equal(tt.want) {
+			t.Errorf("test #%d: %q: got %v, want %v", i, tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestZones(t *testing.T) {
+	res := fakeDNS(t, func(c *records.Config) {
+		c.Zones = []records.Zone{{
+			Domain:     "mesos.corp.example",
+			SOAMname:   "root.ns1.corp.example",
+			SOARname:   "ns1.corp.example",
+			SOARefresh: 60,
+			SOARetry:   600,
+			SOAExpire:  86400,
+		}}
+	})
+
+	for i, tt := range []*dns.Msg{
+		Message(
+			Question("chronos.marathon.mesos.corp.example.", dns.TypeA),
+			Header(true, dns.RcodeSuccess),
+			Answers(
+				A(RRHeader("chronos.marathon.mesos.corp.example.", dns.TypeA, 60),
+					net.ParseIP("1.2.3.11")))),
+		Message(
+			Question("missing.mesos.corp.example.", dns.TypeA),
+			Header(true, dns.RcodeNameError),
+			NSs(
+				SOA(RRHeader("missing.mesos.corp.example.", dns.TypeSOA, 60),
+					"ns1.corp.example", "root.ns1.corp.example", 60))),
+		Message(
+			Question("missing.mesos.", dns.TypeA),
+			Header(true, dns.RcodeNameError),
+			NSs(
+				SOA(RRHeader("missing.mesos.", dns.TypeSOA, 60),
+					"root.ns1.mesos", "ns1.mesos", 60))),
+	} {
+		var rw ResponseRecorder
+		res.HandleMesos(&rw, tt)
+		if got, want := rw.Msg, tt; !reflect.DeepEqual(got, want) {
+			t.Logf("Test #%d\n%v\n", i, pretty.Sprint(tt.Question))
+			t.Error(pretty.Compare(got, want))
+		}
+	}
+}
+
 func TestHandlers(t *testing.T) {
 	res := fakeDNS(t)
 	res.extResolver = exchanger.Func(func(m *dns.Msg, a string) (*dns.Msg, time.Duration, error) {
-		rr1, err := res.formatA("google.com.", "1.1.1.1")
+		rr1, err := res.formatA("google.com.", records.Record{Target: "1.1.1.1"})
 		if err != nil {
 			return nil, 0, err
 		}
-		rr2, err := res.formatA("google.com.", "2.2.2.2")
+		rr2, err := res.formatA("google.com.", records.Record{Target: "2.2.2.2"})
 		if err != nil {
 			return nil, 0, err
 		}
@@ -120,21 +271,21 @@ func TestHandlers(t *testing.T) {
 				Header(true, dns.RcodeSuccess),
 				Answers(
 					SRV(RRHeader("_liquor-store._tcp.marathon.mesos.", dns.TypeSRV, 60),
-						"liquor-store-17700-0.marathon.mesos.", 443, 0, 0),
+						"liquor-store-rn76murd-0.marathon.mesos.", 443, 0, 0),
 					SRV(RRHeader("_liquor-store._tcp.marathon.mesos.", dns.TypeSRV, 60),
-						"liquor-store-7581-1.marathon.mesos.", 80, 0, 0),
+						"liquor-store-ahdjzdbz-1.marathon.mesos.", 80, 0, 0),
 					SRV(RRHeader("_liquor-store._tcp.marathon.mesos.", dns.TypeSRV, 60),
-						"liquor-store-7581-1.marathon.mesos.", 443, 0, 0),
+						"liquor-store-ahdjzdbz-1.marathon.mesos.", 443, 0, 0),
 					SRV(RRHeader("_liquor-store._tcp.marathon.mesos.", dns.TypeSRV, 60),
-						"liquor-store-17700-0.marathon.mesos.", 80, 0, 0)),
+						"liquor-store-rn76murd-0.marathon.mesos.", 80, 0, 0)),
 				Extras(
-					A(RRHeader("liquor-store-17700-0.marathon.mesos.", dns.TypeA, 60),
+					A(RRHeader("liquor-store-rn76murd-0.marathon.mesos.", dns.TypeA, 60),
 						net.ParseIP("10.3.0.1")),
-					A(RRHeader("liquor-store-17700-0.marathon.mesos.", dns.TypeA, 60),
+					A(RRHeader("liquor-store-rn76murd-0.marathon.mesos.", dns.TypeA, 60),
 						net.ParseIP("10.3.0.1")),
-					A(RRHeader("liquor-store-7581-1.marathon.mesos.", dns.TypeA, 60),
+					A(RRHeader("liquor-store-ahdjzdbz-1.marathon.mesos.", dns.TypeA, 60),
 						net.ParseIP("10.3.0.2")),
-					A(RRHeader("liquor-store-7581-1.marathon.mesos.", dns.TypeA, 60),
+					A(RRHeader("liquor-store-ahdjzdbz-1.marathon.mesos.", dns.TypeA, 60),
 						net.ParseIP("10.3.0.2")))),
 		},
 		{
@@ -144,13 +295,13 @@ func TestHandlers(t *testing.T) {
 				Header(true, dns.RcodeSuccess),
 				Answers(
 					SRV(RRHeader("_car-store._udp.marathon.mesos.", dns.TypeSRV, 60),
-						"car-store-50548-0.marathon.slave.mesos.", 31365, 0, 0),
+						"car-store-qrsmip4e-0.marathon.slave.mesos.", 31365, 0, 0),
 					SRV(RRHeader("_car-store._udp.marathon.mesos.", dns.TypeSRV, 60),
-						"car-store-50548-0.marathon.slave.mesos.", 31364, 0, 0)),
+						"car-store-qrsmip4e-0.marathon.slave.mesos.", 31364, 0, 0)),
 				Extras(
-					A(RRHeader("car-store-50548-0.marathon.slave.mesos.", dns.TypeA, 60),
+					A(RRHeader("car-store-qrsmip4e-0.marathon.slave.mesos.", dns.TypeA, 60),
 						net.ParseIP("1.2.3.11")),
-					A(RRHeader("car-store-50548-0.marathon.slave.mesos.", dns.TypeA, 60),
+					A(RRHeader("car-store-qrsmip4e-0.marathon.slave.mesos.", dns.TypeA, 60),
 						net.ParseIP("1.2.3.11")))),
 		},
 		{
@@ -188,6 +339,117 @@ func TestHandlers(t *testing.T) {
 					SOA(RRHeader("chronos.marathon.mesos.", dns.TypeSOA, 60),
 						"root.ns1.mesos", "ns1.mesos", 60))),
 		},
+		{
+			res.HandleMesos,
+			Message(
+				Question("dual-stack.marathon.mesos.", dns.TypeAAAA),
+				Header(true, dns.RcodeSuccess),
+				Answers(
+					AAAA(RRHeader("dual-stack.marathon.mesos.", dns.TypeAAAA, 60),
+						net.ParseIP("fd01:b::1:8000:2")))),
+		},
+		{
+			res.HandleMesos,
+			Message(
+				Question("dual-stack.marathon.mesos.", dns.TypeA),
+				Header(true, dns.RcodeSuccess),
+				Answers(
+					A(RRHeader("dual-stack.marathon.mesos.", dns.TypeA, 60),
+						net.ParseIP("10.3.0.5")))),
+		},
+		{ // CNAME chasing within the zone
+			res.HandleMesos,
+			Message(
+				Question("www.static.mesos.", dns.TypeA),
+				Header(true, dns.RcodeSuccess),
+				Answers(
+					CNAME(RRHeader("www.static.mesos.", dns.TypeCNAME, 60),
+						"chronos.static.mesos."),
+					CNAME(RRHeader("chronos.static.mesos.", dns.TypeCNAME, 60),
+						"chronos.marathon.mesos."),
+					A(RRHeader("chronos.marathon.mesos.", dns.TypeA, 60),
+						net.ParseIP("1.2.3.11")))),
+		},
+		{
+			res.HandleMesos,
+			Message(
+				Question("www.static.mesos.", dns.TypeCNAME),
+				Header(true, dns.RcodeSuccess),
+				Answers(
+					CNAME(RRHeader("www.static.mesos.", dns.TypeCNAME, 60),
+						"chronos.static.mesos."))),
+		},
+		{
+			res.HandleMesos,
+			Message(
+				Question("static.mesos.", dns.TypeTXT),
+				Header(true, dns.RcodeSuccess),
+				Answers(
+					TXT(RRHeader("static.mesos.", dns.TypeTXT, 60), "owner=infra"))),
+		},
+		{
+			res.HandleMesos,
+			Message(
+				Question("nginx.marathon.mesos.", dns.TypeA),
+				Header(true, dns.RcodeSuccess),
+				Answers(
+					A(RRHeader("nginx.marathon.mesos.", dns.TypeA, 5),
+						net.ParseIP("10.3.0.3")))),
+		},
+		{
+			res.HandleMesos,
+			Message(
+				Question("liquor-store-rn76murd-0.marathon.mesos.", dns.TypeTXT),
+				Header(true, dns.RcodeSuccess),
+				Answers(
+					TXT(RRHeader("liquor-store-rn76murd-0.marathon.mesos.", dns.TypeTXT, 60), "version=1.0"),
+					TXT(RRHeader("liquor-store-rn76murd-0.marathon.mesos.", dns.TypeTXT, 60), "environment=prod"),
+					TXT(RRHeader("liquor-store-rn76murd-0.marathon.mesos.", dns.TypeTXT, 60), "team=spirits"),
+					TXT(RRHeader("liquor-store-rn76murd-0.marathon.mesos.", dns.TypeTXT, 60), "location=eu-west"))),
+		},
+		{
+			res.HandleMesos,
+			Message(
+				Question("static.mesos.", dns.TypeMX),
+				Header(true, dns.RcodeSuccess),
+				Answers(
+					MX(RRHeader("static.mesos.", dns.TypeMX, 60), "mail.static.mesos.", 10))),
+		},
+		{
+			res.HandleMesos,
+			Message(
+				Question("sub.static.mesos.", dns.TypeNS),
+				Header(true, dns.RcodeSuccess),
+				Answers(
+					NS(RRHeader("sub.static.mesos.", dns.TypeNS, 60), "ns1.sub.static.mesos."))),
+		},
+		{
+			res.HandleMesos,
+			Message(
+				Question("static.mesos.", dns.TypeA),
+				Header(true, dns.RcodeSuccess),
+				NSs(
+					SOA(RRHeader("static.mesos.", dns.TypeSOA, 60),
+						"root.ns1.mesos", "ns1.mesos", 60))),
+		},
+		{
+			res.HandleMesos,
+			Message(
+				Question("1.0.3.10.in-addr.arpa.", dns.TypePTR),
+				Header(true, dns.RcodeSuccess),
+				Answers(
+					PTR(RRHeader("1.0.3.10.in-addr.arpa.", dns.TypePTR, 60),
+						"liquor-store-rn76murd-0.marathon.mesos."))),
+		},
+		{
+			res.HandleMesos,
+			Message(
+				Question("99.0.3.10.in-addr.arpa.", dns.TypePTR),
+				Header(true, dns.RcodeNameError),
+				NSs(
+					SOA(RRHeader("99.0.3.10.in-addr.arpa.", dns.TypeSOA, 60),
+						"root.ns1.mesos", "ns1.mesos", 60))),
+		},
 		{
 			res.HandleMesos,
 			Message(
@@ -206,6 +468,44 @@ func TestHandlers(t *testing.T) {
 					A(RRHeader("google.com.", dns.TypeA, 60), net.ParseIP("1.1.1.1")),
 					A(RRHeader("google.com.", dns.TypeA, 60), net.ParseIP("2.2.2.2")))),
 		},
+		{ // wildcard CNAME
+			res.HandleMesos,
+			Message(
+				Question("web.apps.mesos.", dns.TypeA),
+				Header(true, dns.RcodeSuccess),
+				Answers(
+					CNAME(RRHeader("web.apps.mesos.", dns.TypeCNAME, 60),
+						"chronos.marathon.mesos."),
+					A(RRHeader("chronos.marathon.mesos.", dns.TypeA, 60),
+						net.ParseIP("1.2.3.11")))),
+		},
+		{ // wildcards don't match below empty non-terminals
+			res.HandleMesos,
+			Message(
+				Question("z.y.apps.mesos.", dns.TypeA),
+				Header(true, dns.RcodeNameError),
+				NSs(
+					SOA(RRHeader("z.y.apps.mesos.", dns.TypeSOA, 60),
+						"root.ns1.mesos", "ns1.mesos", 60))),
+		},
+		{ // empty non-terminals exist
+			res.HandleMesos,
+			Message(
+				Question("y.apps.mesos.", dns.TypeA),
+				Header(true, dns.RcodeSuccess),
+				NSs(
+					SOA(RRHeader("y.apps.mesos.", dns.TypeSOA, 60),
+						"root.ns1.mesos", "ns1.mesos", 60))),
+		},
+		{ // wildcards don't match other labels
+			res.HandleMesos,
+			Message(
+				Question("web.*.mesos.", dns.TypeA),
+				Header(true, dns.RcodeNameError),
+				NSs(
+					SOA(RRHeader("web.*.mesos.", dns.TypeSOA, 60),
+						"root.ns1.mesos", "ns1.mesos", 60))),
+		},
 	} {
 		var rw ResponseRecorder
 		tt.HandlerFunc(&rw, tt.Msg)
@@ -221,6 +521,11 @@ func TestHTTP(t *testing.T) {
 	res := fakeDNS(t)
 	res.version = "0.1.1"
 
+	// secrets of the config are redacted
+	res.config.MesosAuthToken = "s3cr3t"
+	redacted := res.config
+	redacted.MesosAuthToken = "REDACTED"
+
 	res.configureHTTP()
 	srv := httptest.NewServer(http.DefaultServeMux)
 	defer srv.Close()
@@ -237,7 +542,7 @@ func TestHTTP(t *testing.T) {
 				"Version": "0.1.1",
 			},
 		},
-		{"/v1/config", http.StatusOK, &records.Config{}, &res.config},
+		{"/v1/config", http.StatusOK, &records.Config{}, &redacted},
 		{"/v1/services/_leader._tcp.mesos.", http.StatusOK, []interface{}{},
 			[]interface{}{map[string]interface{}{
 				"service": "_leader._tcp.mesos.",
@@ -246,6 +551,24 @@ func TestHTTP(t *testing.T) {
 				"port":    "5050",
 			}},
 		},
+		{"/v1/services/_http._liquor-store._tcp.marathon.mesos.", http.StatusOK, []interface{}{},
+			[]interface{}{
+				map[string]interface{}{
+					"service":   "_http._liquor-store._tcp.marathon.mesos.",
+					"host":      "liquor-store-rn76murd-0.marathon.mesos.",
+					"ip":        "10.3.0.1",
+					"port":      "80",
+					"port_name": "http",
+				},
+				map[string]interface{}{
+					"service":   "_http._liquor-store._tcp.marathon.mesos.",
+					"host":      "liquor-store-ahdjzdbz-1.marathon.mesos.",
+					"ip":        "10.3.0.2",
+					"port":      "80",
+					"port_name": "http",
+				},
+			},
+		},
 		{"/v1/services/_myservice._tcp.mesos.", http.StatusOK, []interface{}{},
 			[]interface{}{map[string]interface{}{
 				"service": "",
@@ -254,12 +577,78 @@ func TestHTTP(t *testing.T) {
 				"port":    "",
 			}},
 		},
+		{"/v1/hosts/dual-stack.marathon.mesos", http.StatusOK, []interface{}{},
+			[]interface{}{
+				map[string]interface{}{
+					"host": "dual-stack.marathon.mesos.",
+					"ip":   "10.3.0.5",
+				},
+				map[string]interface{}{
+					"host": "dual-stack.marathon.mesos.",
+					"ip":   "fd01:b::1:8000:2",
+				},
+			},
+		},
+		{"/v1/agents", http.StatusOK, []interface{}{},
+			[]interface{}{
+				map[string]interface{}{
+					"id":    "20140803-125133-3041283216-5050-2410-0",
+					"names": []interface{}{"20140803-125133-3041283216-5050-2410-0.slave.mesos."},
+					"ips":   []interface{}{"1.2.3.11"},
+					"port":  "5051",
+				},
+				map[string]interface{}{
+					"id":    "20140827-000744-3041283216-5050-2116-1",
+					"names": []interface{}{"20140827-000744-3041283216-5050-2116-1.slave.mesos."},
+					"ips":   []interface{}{"1.2.3.12"},
+					"port":  "5051",
+				},
+				map[string]interface{}{
+					"id":    "20140916-194712-631065744-5050-4798-2",
+					"names": []interface{}{"20140916-194712-631065744-5050-4798-2.slave.mesos."},
+					"ips":   []interface{}{"1.2.3.10"},
+					"port":  "5051",
+				},
+			},
+		},
+		{"/v1/hosts/20140803-125133-3041283216-5050-2410-0.slave.mesos", http.StatusOK, []interface{}{},
+			[]interface{}{
+				map[string]interface{}{
+					"host": "20140803-125133-3041283216-5050-2410-0.slave.mesos.",
+					"ip":   "1.2.3.11",
+				},
+			},
+		},
+		{"/v1/records/static.mesos", http.StatusOK, []interface{}{},
+			[]interface{}{
+				map[string]interface{}{
+					"name":  "static.mesos.",
+					"type":  "TXT",
+					"value": "owner=infra",
+				},
+				map[string]interface{}{
+					"name":  "static.mesos.",
+					"type":  "MX",
+					"value": "10 mail.static.mesos.",
+				},
+			},
+		},
 		{"/v1/hosts/leader.mesos", http.StatusOK, []interface{}{},
 			[]interface{}{map[string]interface{}{
 				"host": "leader.mesos.",
 				"ip":   "1.2.3.4",
 			}},
 		},
+		{"/v1/zones", http.StatusOK, &[]records.Zone{},
+			&[]records.Zone{{
+				Domain:     "mesos",
+				SOAMname:   "ns1.mesos",
+				SOARname:   "root.ns1.mesos",
+				SOARefresh: 60,
+				SOARetry:   600,
+				SOAExpire:  86400,
+			}},
+		},
 	} {
 		if resp, err := http.Get(srv.URL + tt.path); err != nil {
 			t.Error(err)
@@ -275,11 +664,17 @@ func TestHTTP(t *testing.T) {
 	}
 }
 
-func fakeDNS(t *testing.T) *Resolver {
+func fakeDNS(t *testing.T, opts ...func(*records.Config)) *Resolver {
 	config := records.NewConfig()
 	config.Masters = []string{"144.76.157.37:5050"}
 	config.RecurseOn = false
-	config.IPSources = []string{"docker", "mesos", "host"}
+	config.IPSources = []string{"netinfo", "docker", "mesos", "host"}
+	config.EnforceRFC952 = true
+	config.Listener = "127.0.0.1"
+	config.TXTLabels = []string{"team"}
+	for _, opt := range opts {
+		opt(&config)
+	}
 
 	res := New("", config)
 	res.rng.Seed(0) // for deterministic tests
@@ -295,8 +690,17 @@ func fakeDNS(t *testing.T) *Resolver {
 		t.Fatal(err)
 	}
 
-	spec := labels.RFC952
-	err = res.rs.InsertState(sj, "mesos", "mesos-dns.mesos.", "127.0.0.1", res.config.Masters, res.config.IPSources, []records.StaticEntry{}, spec)
+	staticEntries := []records.StaticEntry{
+		{Fqdn: "www.static.mesos.", Type: "CNAME", Value: "chronos.static.mesos."},
+		{Fqdn: "chronos.static.mesos.", Type: "CNAME", Value: "chronos.marathon.mesos."},
+		{Fqdn: "static.mesos.", Type: "TXT", Value: "owner=infra"},
+		{Fqdn: "static.mesos.", Type: "MX", Value: "10 mail.static.mesos."},
+		{Fqdn: "sub.static.mesos.", Type: "NS", Value: "ns1.sub.static.mesos."},
+		{Fqdn: "*.apps.mesos.", Type: "CNAME", Value: "chronos.marathon.mesos."},
+		{Fqdn: "x.y.apps.mesos.", Type: "TXT", Value: "owner=x"},
+	}
+	res.config.StaticEntryConfig.Entries = staticEntries
+	err = res.rs.InsertState(sj, res.config, res.config.Masters)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,131 @@
+package resolver
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mesosphere/mesos-dns/logging"
+	"github.com/mesosphere/mesos-dns/records"
+	"github.com/mesosphere/mesos-dns/records/state"
+)
+
+// resubscribeDelay is the delay before resubscribing after a subscription
+// failed, which doubles with every failure up to maxResubscribeDelay.
+// coalesceDelay is the delay after which the records are rebuilt from the
+// events received since the last rebuild. They're variables for tests.
+var (
+	resubscribeDelay    = time.Second
+	maxResubscribeDelay = 30 * time.Second
+	coalesceDelay       = 250 * time.Millisecond
+)
+
+// errResubscribe ends subscriptions when Resubscribe is called.
+var errResubscribe = errors.New("resubscribing")
+
+// Subscribe keeps the records up to date from the events of the SUBSCRIBE call
+// of the v1 operator API of the leading master until stop is closed, instead
+// of reloading them periodically. Every subscription starts from the full
+// state, so resubscribing after the event stream broke resyncs the records.
+// The records are reloaded while subscribing fails.
+func (res *Resolver) Subscribe(stop <-chan struct{}) {
+	delay := resubscribeDelay
+	for {
+		sub, err := records.Subscribe(res.config, res.getMasters()...)
+		if err == nil {
+			var synced bool
+			synced, err = res.follow(sub, stop)
+			if synced {
+				delay = resubscribeDelay
+			}
+		} else {
+			res.Reload()
+		}
+
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if err == errResubscribe {
+			logging.Verbose.Println("resubscribing to the event stream of the leading master")
+			continue
+		}
+		logging.Error.Printf("event stream of master ended: %v; resubscribing in %v", err, delay)
+
+		select {
+		case <-stop:
+			return
+		case <-res.resubscribe:
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxResubscribeDelay {
+			delay = maxResubscribeDelay
+		}
+	}
+}
+
+// Resubscribe makes Subscribe resubscribe to the leading master and resync
+// the records, e.g. after the master detector found a new leader. Updating
+// the records by reloading them meanwhile could replace those of newer
+// events with older ones.
+func (res *Resolver) Resubscribe() {
+	select {
+	case res.resubscribe <- struct{}{}:
+	default:
+	}
+}
+
+// follow applies the events of the given subscription to the records until it
+// ends, stop is closed or Resubscribe is called, and returns whether it
+// received the full state along with why it ended.
+//
+// Every update rebuilds all records from the state of the cluster, like a
+// reload, though hostnames are only looked up when new or expired in the host
+// cache. To bound that cost, the events received within coalesceDelay of the
+// first one changing the state are applied at once.
+func (res *Resolver) follow(sub *records.Subscription, stop <-chan struct{}) (bool, error) {
+	defer sub.Close()
+
+	cluster := state.NewCluster(sub.Leader)
+	var (
+		synced  bool
+		rebuild <-chan time.Time
+	)
+	for {
+		select {
+		case e, ok := <-sub.Events:
+			if !ok {
+				if rebuild != nil {
+					res.rebuild(cluster)
+				}
+				return synced, sub.Err()
+			}
+			synced = synced || e.Type == "SUBSCRIBED"
+			changed, err := cluster.Apply(e)
+			if err != nil {
+				logging.Error.Printf("cannot apply %s event: %v", e.Type, err)
+			}
+			// the records are only complete once the full state was received
+			if changed && synced && rebuild == nil {
+				rebuild = time.After(coalesceDelay)
+			}
+		case <-rebuild:
+			rebuild = nil
+			res.rebuild(cluster)
+		case <-res.resubscribe:
+			return synced, errResubscribe
+		case <-stop:
+			return synced, nil
+		}
+	}
+}
+
+// rebuild replaces the records with those of the given state of the cluster.
+func (res *Resolver) rebuild(cluster *state.Cluster) {
+	t := records.RecordGenerator{HostCache: res.hosts}
+	if err := t.InsertState(cluster.State(), res.config, res.getMasters()); err != nil {
+		logging.Error.Println(err)
+		return
+	}
+	res.update(&t)
+}
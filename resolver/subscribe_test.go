@@ -0,0 +1,92 @@
+package resolver
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mesosphere/mesos-dns/mesostest"
+	"github.com/mesosphere/mesos-dns/records"
+)
+
+// subscribeState returns the get_state object of a cluster running the given
+// tasks of marathon on a single agent.
+func subscribeState(tasks ...string) string {
+	var list string
+	for i, task := range tasks {
+		if i > 0 {
+			list += ", "
+		}
+		list += fmt.Sprintf(`{"name": %q, "task_id": {"value": "%s.1"}, "framework_id": {"value": "F1"}, "agent_id": {"value": "S1"}, "state": "TASK_RUNNING"}`, task, task)
+	}
+	return `{
+		"get_tasks": {"tasks": [` + list + `]},
+		"get_frameworks": {"frameworks": [{"framework_info": {"id": {"value": "F1"}, "name": "marathon", "hostname": "10.0.0.2"}}]},
+		"get_agents": {"agents": [{"agent_info": {"id": {"value": "S1"}, "hostname": "10.0.0.11"}, "pid": "slave(1)@10.0.0.11:5051"}]}
+	}`
+}
+
+func TestSubscribe(t *testing.T) {
+	defer func(d, c time.Duration) { resubscribeDelay, coalesceDelay = d, c }(resubscribeDelay, coalesceDelay)
+	resubscribeDelay, coalesceDelay = 10*time.Millisecond, 10*time.Millisecond
+
+	m := mesostest.NewMaster(subscribeState("web"))
+	defer m.Close()
+
+	config := records.NewConfig()
+	config.Masters = []string{m.Addr}
+	res := New("", config)
+
+	ch, cancel := res.Watch()
+	defer cancel()
+	changed := func(op records.Op, name string) {
+		select {
+		case changes := <-ch:
+			for _, c := range changes {
+				if c.Op == op && c.Name == name && c.Record.Type == "A" {
+					return
+				}
+			}
+			t.Fatalf("missing %s of %q in %+v", op, name, changes)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s of %q", op, name)
+		}
+	}
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		res.Subscribe(stop)
+		close(done)
+	}()
+
+	// the SUBSCRIBED event holds the full state
+	changed(records.Add, "web.marathon.mesos.")
+	<-m.Subscribed()
+
+	// events update the records incrementally
+	m.Send(`{"type": "TASK_ADDED", "task_added": {"task": {"name": "db", "task_id": {"value": "db.1"}, "framework_id": {"value": "F1"}, "agent_id": {"value": "S1"}, "state": "TASK_RUNNING"}}}`)
+	changed(records.Add, "db.marathon.mesos.")
+	m.Send(`{"type": "TASK_UPDATED", "task_updated": {"framework_id": {"value": "F1"}, "state": "TASK_FINISHED", "status": {"task_id": {"value": "db.1"}, "state": "TASK_FINISHED"}}}`)
+	changed(records.Remove, "db.marathon.mesos.")
+
+	// resubscribing, e.g. after the leader changed, resyncs the records
+	m.SetState(subscribeState("web", "cache"))
+	res.Resubscribe()
+	changed(records.Add, "cache.marathon.mesos.")
+	<-m.Subscribed()
+
+	// changes missed while disconnected are resynced from the full state
+	m.SetState(subscribeState("api"))
+	m.Disconnect()
+	changed(records.Add, "api.marathon.mesos.")
+	if rrs := res.records().As["web.marathon.mesos."]; len(rrs) != 0 {
+		t.Errorf("got records of killed task: %+v", rrs)
+	}
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for Subscribe to return")
+	}
+}
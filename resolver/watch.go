@@ -0,0 +1,124 @@
+package resolver
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/emicklei/go-restful"
+	"github.com/mesosphere/mesos-dns/logging"
+	"github.com/mesosphere/mesos-dns/records"
+)
+
+// watchBuffer is the number of reloads whose changes are buffered for each
+// watcher.
+const watchBuffer = 16
+
+// Watch subscribes to the record changes of every reload which changes any
+// records and returns the channel they're delivered on along with a function
+// which cancels the subscription. Watchers falling behind by more than
+// watchBuffer reloads are unsubscribed and their channel is closed; they need
+// to watch again and re-read the full record set.
+func (res *Resolver) Watch() (<-chan []records.Change, func()) {
+	ch := make(chan []records.Change, watchBuffer)
+
+	res.watchLock.Lock()
+	defer res.watchLock.Unlock()
+	if res.watchers == nil {
+		res.watchers = map[chan []records.Change]struct{}{}
+	}
+	res.watchers[ch] = struct{}{}
+
+	return ch, func() {
+		res.watchLock.Lock()
+		defer res.watchLock.Unlock()
+		if _, ok := res.watchers[ch]; ok {
+			delete(res.watchers, ch)
+			close(ch)
+		}
+	}
+}
+
+// publish logs and counts the given record changes of a reload and delivers
+// them to all watchers.
+func (res *Resolver) publish(changes []records.Change) {
+	if len(changes) == 0 {
+		return
+	}
+
+	added, removed := records.Summary(changes)
+	logging.Verbose.Printf("records changed: %d added, %d removed", added, removed)
+	for _, c := range changes {
+		logging.VeryVerbose.Printf("%s\t%s\t%s\t%s", c.Op, c.Name, c.Record.Type, c.Record)
+		if c.Op == records.Add {
+			logging.CurLog.RecordsAdded.Inc()
+		} else {
+			logging.CurLog.RecordsRemoved.Inc()
+		}
+	}
+
+	res.watchLock.Lock()
+	defer res.watchLock.Unlock()
+	for ch := range res.watchers {
+		select {
+		case ch <- changes:
+		default:
+			logging.Error.Println("dropping record changes watcher which fell behind")
+			delete(res.watchers, ch)
+			close(ch)
+		}
+	}
+}
+
+// RestWatch handles HTTP requests streaming the record changes of reloads as
+// JSON arrays, one per line, until the client disconnects. The stream ends
+// if the client falls behind.
+func (res *Resolver) RestWatch(req *restful.Request, resp *restful.Response) {
+	ch, cancel := res.Watch()
+	defer cancel()
+
+	external := res.isExternal(clientIP(req.Request.RemoteAddr))
+	flusher, _ := resp.ResponseWriter.(http.Flusher)
+
+	resp.AddHeader("Content-Type", "application/json")
+	resp.WriteHeader(http.StatusOK)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	enc := json.NewEncoder(resp)
+	for {
+		select {
+		case changes, ok := <-ch:
+			if !ok {
+				return
+			}
+			if external {
+				changes = externalChanges(changes)
+			}
+			if len(changes) == 0 {
+				continue
+			}
+			if err := enc.Encode(changes); err != nil {
+				logging.Error.Println(err)
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		case <-req.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// externalChanges returns the given changes without those of records with
+// CLUSTER visibility.
+func externalChanges(changes []records.Change) []records.Change {
+	ext := make([]records.Change, 0, len(changes))
+	for _, c := range changes {
+		if c.Record.Visibility != "CLUSTER" {
+			ext = append(ext, c)
+		}
+	}
+	return ext
+}
@@ -0,0 +1,155 @@
+package resolver
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/mesosphere/mesos-dns/records"
+)
+
+func TestReloadChanges(t *testing.T) {
+	b, err := ioutil.ReadFile("../factories/fake.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the state is served as plain JSON objects since not all of its types
+	// marshal back into the format of the master
+	var sj map[string]interface{}
+	if err = json.Unmarshal(b, &sj); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	master := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		_ = json.NewEncoder(w).Encode(sj)
+	}))
+	defer master.Close()
+	addr := strings.TrimPrefix(master.URL, "http://")
+	sj["leader"] = "master@" + addr
+
+	config := records.NewConfig()
+	config.Masters = []string{addr}
+	res := New("", config)
+
+	ch, cancel := res.Watch()
+	recv := func() []records.Change {
+		select {
+		case changes := <-ch:
+			return changes
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for changes")
+			return nil
+		}
+	}
+
+	res.Reload()
+	if added, removed := records.Summary(recv()); added == 0 || removed != 0 {
+		t.Errorf("initial reload: got %d added and %d removed records", added, removed)
+	}
+
+	// reloading the same state doesn't change anything
+	res.Reload()
+	select {
+	case changes := <-ch:
+		t.Errorf("unexpected changes: %+v", changes)
+	default:
+	}
+
+	// killing nginx removes its records
+	mu.Lock()
+	for _, f := range sj["frameworks"].([]interface{}) {
+		for _, task := range f.(map[string]interface{})["tasks"].([]interface{}) {
+			if task := task.(map[string]interface{}); task["name"] == "nginx" {
+				task["state"] = "TASK_KILLED"
+			}
+		}
+	}
+	mu.Unlock()
+	res.Reload()
+
+	changes := recv()
+	if added, _ := records.Summary(changes); added != 0 {
+		t.Errorf("unexpected additions: %+v", changes)
+	}
+	want := records.Change{Op: records.Remove, Name: "nginx.marathon.mesos."}
+	var found bool
+	for _, c := range changes {
+		found = found || c.Op == want.Op && c.Name == want.Name && c.Record.Type == "A"
+	}
+	if !found {
+		t.Errorf("missing removal of %q in %+v", want.Name, changes)
+	}
+
+	cancel()
+	cancel() // cancelling twice is harmless
+	if _, ok := <-ch; ok {
+		t.Error("expected closed channel after cancelling")
+	}
+}
+
+func TestWatchFallingBehind(t *testing.T) {
+	res := New("", records.NewConfig())
+	ch, cancel := res.Watch()
+	defer cancel()
+
+	changes := []records.Change{{Op: records.Add, Name: "web.mesos.", Record: records.Record{Type: "A", Target: "1.2.3.4"}}}
+	for i := 0; i <= watchBuffer; i++ {
+		res.publish(changes)
+	}
+	for i := 0; i < watchBuffer; i++ {
+		if got := <-ch; !reflect.DeepEqual(got, changes) {
+			t.Fatalf("got %+v, want %+v", got, changes)
+		}
+	}
+	if _, ok := <-ch; ok {
+		t.Error("expected closed channel after falling behind")
+	}
+}
+
+func TestRestWatch(t *testing.T) {
+	res := fakeDNS(t)
+	_, ipnet, _ := net.ParseCIDR("10.0.0.0/8")
+	res.clusterNets = []*net.IPNet{ipnet} // the test client is external
+
+	// earlier tests registered the routes of other resolvers with the
+	// default container
+	ws := new(restful.WebService)
+	ws.Route(ws.GET("/v1/watch").To(res.RestWatch))
+	srv := httptest.NewServer(restful.NewContainer().Add(ws))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/v1/watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	external := records.Change{Op: records.Add, Name: "web.mesos.", Record: records.Record{Type: "A", Target: "1.2.3.4"}}
+	cluster := records.Change{Op: records.Remove, Name: "db.mesos.", Record: records.Record{Type: "A", Target: "10.0.0.1", Visibility: "CLUSTER"}}
+	res.publish([]records.Change{cluster}) // not visible at all
+	res.publish([]records.Change{external, cluster})
+
+	line, err := bufio.NewReader(resp.Body).ReadBytes('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []records.Change
+	if err = json.Unmarshal(line, &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := []records.Change{external}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,125 @@
+package resolver
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/emicklei/go-restful"
+	"github.com/mesosphere/mesos-dns/logging"
+	"github.com/mesosphere/mesos-dns/records"
+	"github.com/miekg/dns"
+)
+
+// WriteZone writes all records of the given authoritative zone, e.g. "mesos",
+// to w as an RFC 1035 master file.
+func (res *Resolver) WriteZone(w io.Writer, domain string) error {
+	return res.writeZone(w, res.records(), domain)
+}
+
+// writeZone writes the records of the given zone held by the given
+// RecordGenerator to w as an RFC 1035 master file: its SOA and NS records
+// followed by the records of each name in the zone, sorted by name.
+func (res *Resolver) writeZone(w io.Writer, rs *records.RecordGenerator, domain string) error {
+	domain = strings.ToLower(strings.TrimRight(domain, "."))
+	zone := res.config.ZoneOf(domain)
+	if zone.Domain != domain {
+		return fmt.Errorf("%q is not an authoritative zone", domain)
+	}
+	apex := domain + "."
+
+	var errs multiError
+	rrs := make([]dns.RR, 0, len(rs.As)+len(rs.SRVs))
+	soa, err := res.formatSOA(apex)
+	if err != nil {
+		return err
+	}
+	rrs = append(rrs, soa)
+
+	// the name server of the zone unless there are static NS records for it
+	if len(rs.NSs[apex]) == 0 {
+		ns, err := res.formatNS(apex, records.Record{Target: zone.SOAMname})
+		if err != nil {
+			return err
+		}
+		rrs = append(rrs, ns)
+	}
+
+	for _, name := range rs.Names() {
+		// names of nested zones and reverse names belong to other zones
+		if res.config.ZoneOf(name).Domain != domain || !inDomain(name, apex) {
+			continue
+		}
+		for _, rtype := range records.RecordTypes {
+			for _, rr := range rs.Records(name, rtype) {
+				if r, err := res.formatRR(name, rtype, rr); err != nil {
+					errs = errs.Add(fmt.Errorf("%s %s %s: %v", name, rtype, rr, err))
+				} else {
+					rrs = append(rrs, r)
+				}
+			}
+		}
+	}
+
+	if !errs.Nil() {
+		logging.Error.Println(errs.Error())
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "$ORIGIN %s\n$TTL %d\n", apex, res.config.TTL)
+	for _, rr := range rrs {
+		fmt.Fprintln(bw, rr.String())
+	}
+	return bw.Flush()
+}
+
+// inDomain returns whether the given name is the given domain or below it.
+func inDomain(name, domain string) bool {
+	return name == domain || strings.HasSuffix(name, "."+domain)
+}
+
+// formatRR returns the resource record of the given type for rr
+func (res *Resolver) formatRR(name, rtype string, rr records.Record) (dns.RR, error) {
+	switch rtype {
+	case "A":
+		return res.formatA(name, rr)
+	case "AAAA":
+		return res.formatAAAA(name, rr)
+	case "SRV":
+		return res.formatSRV(name, rr)
+	case "PTR":
+		return res.formatPTR(name, rr)
+	case "CNAME":
+		return res.formatCNAME(name, rr)
+	case "TXT":
+		return res.formatTXT(name, rr)
+	case "MX":
+		return res.formatMX(name, rr)
+	case "NS":
+		return res.formatNS(name, rr)
+	default:
+		return nil, fmt.Errorf("unsupported record type %q", rtype)
+	}
+}
+
+// RestZone handles HTTP requests of all records of the given authoritative
+// zone as an RFC 1035 master file.
+func (res *Resolver) RestZone(req *restful.Request, resp *restful.Response) {
+	rs := res.visibleRecords(req.Request.RemoteAddr)
+
+	var b bytes.Buffer
+	if err := res.writeZone(&b, rs, req.PathParameter("zone")); err != nil {
+		if err = resp.WriteErrorString(http.StatusNotFound, err.Error()); err != nil {
+			logging.Error.Println(err)
+		}
+		return
+	}
+
+	resp.AddHeader("Content-Type", "text/dns")
+	if _, err := resp.Write(b.Bytes()); err != nil {
+		logging.Error.Println(err)
+	}
+}
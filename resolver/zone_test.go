@@ -0,0 +1,140 @@
+package resolver
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/mesosphere/mesos-dns/records"
+	"github.com/miekg/dns"
+)
+
+func TestWriteZone(t *testing.T) {
+	res := fakeDNS(t, func(c *records.Config) {
+		c.SOAMname = "ns1.mesos."
+		c.SOARname = "root.ns1.mesos."
+		c.Zones = []records.Zone{{
+			Domain:     "mesos.corp.example",
+			SOAMname:   "root.ns1.corp.example.",
+			SOARname:   "ns1.corp.example.",
+			SOARefresh: 60,
+			SOARetry:   600,
+			SOAExpire:  86400,
+		}}
+	})
+
+	for i, tt := range []struct {
+		zone          string
+		err           bool
+		first         dns.RR
+		want, notWant []string
+	}{
+		{
+			zone: "mesos",
+			first: &dns.SOA{
+				Hdr:     dns.RR_Header{Name: "mesos.", Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 60},
+				Ns:      "root.ns1.mesos.",
+				Mbox:    "ns1.mesos.",
+				Refresh: 60,
+				Retry:   600,
+				Expire:  86400,
+				Minttl:  60,
+			},
+			want: []string{
+				"mesos.\t60\tIN\tNS\tns1.mesos.",
+				"chronos.marathon.mesos.\t60\tIN\tA\t1.2.3.11",
+				"*.apps.mesos.\t60\tIN\tCNAME\tchronos.marathon.mesos.",
+				"_leader._tcp.mesos.\t60\tIN\tSRV\t0 0 5050 leader.mesos.",
+				"static.mesos.\t60\tIN\tTXT\t\"owner=infra\"",
+				"sub.static.mesos.\t60\tIN\tNS\tns1.sub.static.mesos.",
+			},
+			notWant: []string{"mesos.corp.example.", "in-addr.arpa."},
+		},
+		{
+			zone: "Mesos.Corp.Example.",
+			first: &dns.SOA{
+				Hdr:     dns.RR_Header{Name: "mesos.corp.example.", Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 60},
+				Ns:      "ns1.corp.example.",
+				Mbox:    "root.ns1.corp.example.",
+				Refresh: 60,
+				Retry:   600,
+				Expire:  86400,
+				Minttl:  60,
+			},
+			want: []string{
+				"chronos.marathon.mesos.corp.example.\t60\tIN\tA\t1.2.3.11",
+			},
+			notWant: []string{"\tchronos.marathon.mesos.\t"},
+		},
+		{zone: "example", err: true},
+	} {
+		var b bytes.Buffer
+		if err := res.WriteZone(&b, tt.zone); (err != nil) != tt.err {
+			t.Errorf("test #%d: unexpected error: %v", i, err)
+			continue
+		} else if tt.err {
+			continue
+		}
+
+		// the zone must be a valid master file
+		var rrs []dns.RR
+		for tok := range dns.ParseZone(bytes.NewReader(b.Bytes()), "", "") {
+			if tok.Error != nil {
+				t.Fatalf("test #%d: invalid master file: %v\n%s", i, tok.Error, b.String())
+			}
+			rrs = append(rrs, tok.RR)
+		}
+		if len(rrs) == 0 || rrs[0].String() != tt.first.String() {
+			t.Errorf("test #%d: expected %q first, got %v", i, tt.first, rrs)
+		}
+
+		zone := b.String()
+		for _, rr := range tt.want {
+			if !strings.Contains(zone, "\n"+rr+"\n") {
+				t.Errorf("test #%d: missing %q in zone:\n%s", i, rr, zone)
+			}
+		}
+		for _, s := range tt.notWant {
+			if strings.Contains(zone, s) {
+				t.Errorf("test #%d: unexpected %q in zone:\n%s", i, s, zone)
+			}
+		}
+	}
+}
+
+func TestRestZone(t *testing.T) {
+	res := fakeDNS(t)
+	ws := new(restful.WebService)
+	ws.Route(ws.GET("/v1/zones/{zone}").To(res.RestZone))
+	srv := httptest.NewServer(restful.NewContainer().Add(ws))
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		path string
+		code int
+		want string
+	}{
+		{"/v1/zones/mesos", http.StatusOK, "$ORIGIN mesos.\n$TTL 60\nmesos.\t60\tIN\tSOA\t"},
+		{"/v1/zones/example", http.StatusNotFound, ""},
+	} {
+		resp, err := http.Get(srv.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.code {
+			t.Errorf("%s: got status %d, want %d", tt.path, resp.StatusCode, tt.code)
+		}
+		if !strings.HasPrefix(string(body), tt.want) {
+			t.Errorf("%s: got %q, want prefix %q", tt.path, body, tt.want)
+		}
+	}
+}